@@ -0,0 +1,298 @@
+/*
+Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package es
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hebe/langs/goreq"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// aliasCmd represents the es command
+var aliasCmd = &cobra.Command{
+	Use:   "alias",
+	Short: "Add, remove, swap and move aliases atomically",
+	Long: `Change aliases with a single request of the _aliases API, so that searches and writes
+through an alias never see it missing or on both indices. The actions are listed first;
+--dry-run prints the request instead, and actions removing aliases ask for confirmation
+unless --yes is given, e.g.
+
+  hebe es alias add logs-errors 'logs-2026.10.*' --filter '{"term": {"level": "error"}}'
+  hebe es alias add logs-write logs-2026.10.16 --write-index
+  hebe es alias remove logs-all logs-2025.01.01
+  hebe es alias swap --alias logs-current --from logs-v1 --to logs-v2
+  hebe es alias move logs-write logs-2026.10.17
+
+swap and move keep the filter, routing and write index of the alias unless given.`,
+}
+
+func init() {
+	EsCmd.AddCommand(aliasCmd)
+	addConfirmFlags(aliasCmd)
+	aliasCmd.AddCommand(aliasAddCmd, aliasRemoveCmd, aliasSwapCmd, aliasMoveCmd)
+	for _, cmd := range []*cobra.Command{aliasAddCmd, aliasSwapCmd, aliasMoveCmd} {
+		cmd.Flags().String("filter", "", "JSON query filtering the documents seen through the alias")
+		cmd.Flags().String("routing", "", "routing of both the writes and searches through the alias")
+		cmd.Flags().String("index-routing", "", "routing of the writes through the alias")
+		cmd.Flags().String("search-routing", "", "routing of the searches through the alias")
+		cmd.Flags().Bool("write-index", false, "make the index the write index of the alias, --write-index=false to unset it")
+	}
+	aliasSwapCmd.Flags().String("alias", "", "alias to swap")
+	aliasSwapCmd.Flags().String("from", "", "index the alias is on")
+	aliasSwapCmd.Flags().String("to", "", "index to put the alias on instead")
+	for _, name := range []string{"alias", "from", "to"} {
+		aliasSwapCmd.MarkFlagRequired(name)
+	}
+}
+
+// aliasAction is an add or remove action of the _aliases API.
+type aliasAction struct {
+	action string
+	index  string
+	alias  string
+	params map[string]interface{}
+}
+
+func (a aliasAction) body() map[string]interface{} {
+	action := map[string]interface{}{"index": a.index, "alias": a.alias}
+	for k, v := range a.params {
+		action[k] = v
+	}
+	return map[string]interface{}{a.action: action}
+}
+
+// aliasParams returns the parameters of an alias given by flags, over the current ones.
+func aliasParams(cmd *cobra.Command, current map[string]interface{}) (map[string]interface{}, error) {
+	params := map[string]interface{}{}
+	for k, v := range current {
+		params[k] = v
+	}
+	filter, err := cmd.Flags().GetString("filter")
+	if err != nil {
+		return nil, err
+	}
+	if filter != "" {
+		var query map[string]interface{}
+		d := json.NewDecoder(strings.NewReader(filter))
+		d.UseNumber()
+		if err := d.Decode(&query); err != nil {
+			return nil, fmt.Errorf("invalid --filter: %v", err)
+		}
+		params["filter"] = query
+	}
+	if routing, _ := cmd.Flags().GetString("routing"); routing != "" {
+		delete(params, "index_routing")
+		delete(params, "search_routing")
+		params["routing"] = routing
+	}
+	for _, name := range []string{"index-routing", "search-routing"} {
+		if routing, _ := cmd.Flags().GetString(name); routing != "" {
+			params[strings.Replace(name, "-", "_", 1)] = routing
+		}
+	}
+	if cmd.Flags().Changed("write-index") {
+		params["is_write_index"], _ = cmd.Flags().GetBool("write-index")
+	}
+	return params, nil
+}
+
+// aliasIndices returns the indices an alias is on, with its parameters on each.
+func aliasIndices(client *Client, alias string) (map[string]map[string]interface{}, error) {
+	data, err := client.Request(goreq.GET, "_alias/"+url.PathEscape(alias), nil)
+	if e, ok := err.(*ResponseError); ok && e.Status == 404 {
+		return nil, fmt.Errorf("alias %s not found", alias)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var resp map[string]struct {
+		Aliases map[string]map[string]interface{} `json:"aliases"`
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&resp); err != nil {
+		return nil, err
+	}
+	indices := map[string]map[string]interface{}{}
+	for index, v := range resp {
+		if params, ok := v.Aliases[alias]; ok {
+			indices[index] = params
+		}
+	}
+	if len(indices) == 0 {
+		return nil, fmt.Errorf("alias %s not found", alias)
+	}
+	return indices, nil
+}
+
+// runAliasActions lists the actions then sends them in one request, after confirmation when
+// an alias is removed.
+func runAliasActions(client *Client, actions []aliasAction) {
+	body := map[string]interface{}{}
+	var list []interface{}
+	for _, a := range actions {
+		list = append(list, a.body())
+	}
+	body["actions"] = list
+	if confirmFlags.dryRun {
+		printRequest(goreq.POST, "_aliases", body)
+		return
+	}
+	t := &Table{Columns: []string{"action", "index", "alias", "params"}}
+	removes := 0
+	for _, a := range actions {
+		var params interface{}
+		if len(a.params) > 0 {
+			params = a.params
+		}
+		t.Rows = append(t.Rows, []interface{}{a.action, a.index, a.alias, params})
+		if a.action == "remove" {
+			removes++
+		}
+	}
+	check(t.Write(os.Stderr, "table"))
+	if removes > 0 {
+		ok, err := confirm(fmt.Sprintf("Apply %d alias actions?", len(actions)), confirmFlags.yes)
+		check(err)
+		if !ok {
+			os.Exit(exitError)
+		}
+	}
+	_, err := client.Request(goreq.POST, "_aliases", body)
+	check(err)
+	for _, a := range actions {
+		if a.action == "add" {
+			fmt.Printf("added %s to %s\n", a.alias, a.index)
+		} else {
+			fmt.Printf("removed %s from %s\n", a.alias, a.index)
+		}
+	}
+}
+
+var aliasAddCmd = &cobra.Command{
+	Use:   "add <alias> <index>...",
+	Short: "Add an alias to indices",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		params, err := aliasParams(cmd, nil)
+		check(err)
+		client, err := newClient(cluster)
+		check(err)
+		var actions []aliasAction
+		for _, index := range args[1:] {
+			actions = append(actions, aliasAction{"add", index, args[0], params})
+		}
+		runAliasActions(client, actions)
+	},
+}
+
+var aliasRemoveCmd = &cobra.Command{
+	Use:   "remove <alias> [index]...",
+	Short: "Remove an alias from indices, all of them when none are given",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		client, err := newClient(cluster)
+		check(err)
+		indices := args[1:]
+		if len(indices) == 0 {
+			current, err := aliasIndices(client, args[0])
+			check(err)
+			for index := range current {
+				indices = append(indices, index)
+			}
+			sort.Strings(indices)
+		}
+		var actions []aliasAction
+		for _, index := range indices {
+			actions = append(actions, aliasAction{"remove", index, args[0], nil})
+		}
+		runAliasActions(client, actions)
+	},
+}
+
+var aliasSwapCmd = &cobra.Command{
+	Use:   "swap --alias <alias> --from <index> --to <index>",
+	Short: "Move an alias from one index to another, e.g. after a reindex",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		alias, err := cmd.Flags().GetString("alias")
+		check(err)
+		from, err := cmd.Flags().GetString("from")
+		check(err)
+		to, err := cmd.Flags().GetString("to")
+		check(err)
+		if from == to {
+			check(errors.New("--from and --to are the same index"))
+		}
+		client, err := newClient(cluster)
+		check(err)
+		current, err := aliasIndices(client, alias)
+		check(err)
+		if _, ok := current[from]; !ok {
+			check(fmt.Errorf("alias %s is not on %s", alias, from))
+		}
+		params, err := aliasParams(cmd, current[from])
+		check(err)
+		runAliasActions(client, []aliasAction{
+			{"remove", from, alias, nil},
+			{"add", to, alias, params},
+		})
+	},
+}
+
+var aliasMoveCmd = &cobra.Command{
+	Use:   "move <alias> <index>",
+	Short: "Move an alias to an index, removing it from all the others",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		alias, target := args[0], args[1]
+		client, err := newClient(cluster)
+		check(err)
+		current, err := aliasIndices(client, alias)
+		check(err)
+		var indices []string
+		for index := range current {
+			indices = append(indices, index)
+		}
+		sort.Strings(indices)
+		// the parameters are only kept when unambiguous
+		var kept map[string]interface{}
+		if len(indices) == 1 {
+			kept = current[indices[0]]
+		}
+		params, err := aliasParams(cmd, kept)
+		check(err)
+		var actions []aliasAction
+		for _, index := range indices {
+			if index != target {
+				actions = append(actions, aliasAction{"remove", index, alias, nil})
+			}
+		}
+		runAliasActions(client, append(actions, aliasAction{"add", target, alias, params}))
+	},
+}
@@ -31,13 +31,20 @@ This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		cluster := cmd.Flag("cluster").Value.String()
+
+		index, err := cmd.Flags().GetString("index")
+		check(err)
+		if index != "" {
+			// _cat/aliases selects aliases by name only
+			catFlags.where = append(catFlags.where, "index="+index)
+		}
 		handleCatCommand(cluster, "aliases")
 	},
 }
 
 func init() {
 	EsCmd.AddCommand(aliasesCmd)
+	addCatFlags(aliasesCmd)
 
-	aliasesCmd.Flags().StringP("cluster", "c", "localhost:9200", "es cluster")
 	aliasesCmd.Flags().StringP("index", "i", "", "index pattern")
 }
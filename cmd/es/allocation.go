@@ -31,13 +31,13 @@ This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		cluster := cmd.Flag("cluster").Value.String()
-		handleCatCommand(cluster, "allocation", "h=shards,disk.total,disk.used,ip,node")
+		handleCatCommand(cluster, "allocation", "h=shards,disk.total,disk.used,disk.percent,ip,node")
 
 	},
 }
 
 func init() {
 	EsCmd.AddCommand(allocationCmd)
-
-	allocationCmd.Flags().StringP("cluster", "c", "localhost:9200", "es cluster")
+	addCatFlags(allocationCmd)
+	addCheckFlags(allocationCmd)
 }
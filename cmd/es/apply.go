@@ -0,0 +1,732 @@
+/*
+Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package es
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hebe/langs/goreq"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// planCmd represents the es command
+var planCmd = &cobra.Command{
+	Use:   "plan -f <dir>",
+	Short: "Show the changes apply would make to the cluster",
+	Long: `Compare a directory of resource files with the cluster, listing the resources apply would
+create, update or, with --prune, delete, and how they differ by key. The command exits
+with 1 when the cluster differs from the files, e.g.
+
+  hebe es plan -f elasticsearch/
+  hebe es plan -f elasticsearch/ --prune -c prod-logs
+
+See apply for the format of the files.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		dir, err := cmd.Flags().GetString("file")
+		check(err)
+		prune, err := cmd.Flags().GetBool("prune")
+		check(err)
+		client, err := newClient(cluster)
+		check(err)
+		changes, err := planResources(client, dir, prune)
+		check(err)
+		if len(changes) == 0 {
+			fmt.Fprintln(os.Stderr, "no changes, the cluster matches the files")
+			return
+		}
+		check(resourceChanges(changes).Write(os.Stdout, outputFormat))
+		fmt.Fprintln(os.Stderr, planSummary(changes))
+		os.Exit(exitError)
+	},
+}
+
+// applyCmd represents the es command
+var applyCmd = &cobra.Command{
+	Use:   "apply -f <dir>",
+	Short: "Reconcile the cluster with a directory of resource files",
+	Long: `Reconcile the cluster with a directory of YAML or JSON resource files. The differences are
+shown as by plan, then the resources which are missing or differ are put after
+confirmation, in dependency order, so applying twice changes nothing. With --prune, the
+resources of the cluster missing from the files are deleted too, only for the kinds the
+files declare; the names starting with a dot and the resources the cluster manages
+itself are left alone, and deleting ILM policies indices use or component templates
+index templates are composed of is refused before any change is made.
+
+Each file holds resources as YAML documents, or as a JSON object or list:
+
+  kind: index_template
+  name: logs
+  spec:
+    index_patterns: [logs-*]
+    composed_of: [logs-base]
+
+The spec is the body of the request putting the resource. The kinds are component_template,
+index_template, legacy_template, ilm_policy, ingest_pipeline, snapshot_repository, alias,
+whose spec holds the index along with the filter, routing and is_write_index of the alias,
+and cluster_settings, unnamed, whose spec holds persistent settings, e.g.
+
+  hebe es apply -f elasticsearch/ --dry-run
+  hebe es apply -f elasticsearch/ --prune -c prod-logs`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		dir, err := cmd.Flags().GetString("file")
+		check(err)
+		prune, err := cmd.Flags().GetBool("prune")
+		check(err)
+		client, err := newClient(cluster)
+		check(err)
+		changes, err := planResources(client, dir, prune)
+		check(err)
+		if len(changes) == 0 {
+			fmt.Fprintln(os.Stderr, "no changes, the cluster matches the files")
+			return
+		}
+		check(resourceChanges(changes).Write(os.Stdout, outputFormat))
+		fmt.Fprintln(os.Stderr, planSummary(changes))
+		requests := applyRequests(changes)
+		if confirmFlags.dryRun {
+			for _, r := range requests {
+				printRequest(r.method, r.path, r.body)
+			}
+			return
+		}
+		ok, err := confirm(fmt.Sprintf("Apply the changes to %s?", client.cluster.Name), confirmFlags.yes)
+		check(err)
+		if !ok {
+			os.Exit(exitError)
+		}
+		for _, r := range requests {
+			_, err := client.Request(r.method, r.path, r.body)
+			check(err)
+			fmt.Println(r.label)
+		}
+	},
+}
+
+func init() {
+	EsCmd.AddCommand(planCmd, applyCmd)
+	addConfirmFlags(applyCmd)
+	for _, cmd := range []*cobra.Command{planCmd, applyCmd} {
+		cmd.Flags().StringP("file", "f", "", "directory of YAML or JSON resource files")
+		cmd.Flags().Bool("prune", false, "delete the resources of the declared kinds missing from the files")
+		cmd.MarkFlagRequired("file")
+	}
+}
+
+// resourceKind is a kind of resource files declare, and how to read and compare those of the cluster.
+type resourceKind struct {
+	name     string
+	endpoint string // PUT and DELETE <endpoint>/<name>
+	list     func(client *Client) (map[string]map[string]interface{}, error)
+	flatten  func(spec map[string]interface{}) map[string]string
+	merged   bool // put merges the spec with the current one, the keys it lacks are only reset by --prune
+}
+
+// resourceKinds in the order they are put, each kind only depending on the previous ones,
+// and deleted in reverse order.
+var resourceKinds = []resourceKind{
+	{"cluster_settings", "_cluster/settings", listClusterSettings, flattenSpec, true},
+	{"snapshot_repository", "_snapshot", listObjects("_snapshot"), flattenSpec, false},
+	{"ingest_pipeline", "_ingest/pipeline", listObjects("_ingest/pipeline"), flattenSpec, false},
+	{"ilm_policy", "_ilm/policy", listPolicies, flattenPolicy, false},
+	{"component_template", "_component_template", listTemplateResources(templateKinds[0]), flattenTemplate, false},
+	{"index_template", "_index_template", listTemplateResources(templateKinds[1]), flattenTemplate, false},
+	{"legacy_template", "_template", listTemplateResources(templateKinds[2]), flattenTemplate, false},
+	{"alias", "_aliases", listAliases, flattenAlias, false},
+}
+
+func resourceKindNamed(name string) (*resourceKind, error) {
+	var names []string
+	for i := range resourceKinds {
+		if resourceKinds[i].name == name {
+			return &resourceKinds[i], nil
+		}
+		names = append(names, resourceKinds[i].name)
+	}
+	return nil, fmt.Errorf("unknown kind %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// resource is a resource declared by a file. Aliases are named <alias>/<index>, being one per index,
+// and the cluster settings are a single resource named cluster.
+type resource struct {
+	kind *resourceKind
+	name string
+	spec map[string]interface{}
+	file string
+}
+
+// readResources reads the .yaml, .yml and .json files of dir and its subdirectories,
+// in the order of resourceKinds then by name.
+func readResources(dir string) ([]resource, error) {
+	var resources []resource
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		switch filepath.Ext(file) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+		docs, err := readResourceFile(file)
+		if err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			r, err := parseResource(doc)
+			if err != nil {
+				return fmt.Errorf("%s: %v", file, err)
+			}
+			r.file = file
+			resources = append(resources, r)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resources) == 0 {
+		return nil, fmt.Errorf("no resource files in %s", dir)
+	}
+	order := map[string]int{}
+	for i, k := range resourceKinds {
+		order[k.name] = i
+	}
+	sort.SliceStable(resources, func(i, j int) bool {
+		a, b := resources[i], resources[j]
+		if a.kind != b.kind {
+			return order[a.kind.name] < order[b.kind.name]
+		}
+		return a.name < b.name
+	})
+	for i := 1; i < len(resources); i++ {
+		a, b := resources[i-1], resources[i]
+		if a.kind == b.kind && a.name == b.name {
+			return nil, fmt.Errorf("%s %s is declared twice, in %s and %s", a.kind.name, a.name, a.file, b.file)
+		}
+	}
+	return resources, nil
+}
+
+// readResourceFile returns the objects of a file, the documents of a YAML file or the object or
+// list of a JSON file.
+func readResourceFile(file string) ([]map[string]interface{}, error) {
+	data, err := readFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var values []interface{}
+	if filepath.Ext(file) == ".json" {
+		var v interface{}
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.UseNumber()
+		if err := d.Decode(&v); err != nil {
+			return nil, fmt.Errorf("invalid JSON in %s: %v", file, err)
+		}
+		values = append(values, v)
+	} else {
+		d := yaml.NewDecoder(bytes.NewReader(data))
+		for {
+			var v interface{}
+			err := d.Decode(&v)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid YAML in %s: %v", file, err)
+			}
+			values = append(values, stringKeys(v))
+		}
+	}
+	var docs []map[string]interface{}
+	for _, v := range values {
+		list, ok := v.([]interface{})
+		if !ok {
+			list = []interface{}{v}
+		}
+		for _, item := range list {
+			switch item := item.(type) {
+			case nil:
+			case map[string]interface{}:
+				docs = append(docs, item)
+			default:
+				return nil, fmt.Errorf("%s: expected resources with a kind, name and spec, got %s", file, cell(item))
+			}
+		}
+	}
+	return docs, nil
+}
+
+// stringKeys converts the maps decoded from YAML to the maps with string keys of JSON.
+func stringKeys(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[fmt.Sprint(k)] = stringKeys(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range v {
+			v[i] = stringKeys(item)
+		}
+	}
+	return v
+}
+
+func parseResource(doc map[string]interface{}) (resource, error) {
+	var r resource
+	kind, err := resourceKindNamed(cell(doc["kind"]))
+	if err != nil {
+		return r, err
+	}
+	name := cell(doc["name"])
+	spec, ok := doc["spec"].(map[string]interface{})
+	if !ok {
+		return r, fmt.Errorf("%s %s has no spec", kind.name, name)
+	}
+	switch kind.name {
+	case "cluster_settings":
+		name = "cluster"
+	case "alias":
+		index := cell(spec["index"])
+		if index == "" {
+			return r, fmt.Errorf("alias %s has no index", name)
+		}
+		name += "/" + index
+		params := map[string]interface{}{}
+		for k, v := range spec {
+			if k != "index" {
+				params[k] = v
+			}
+		}
+		spec = params
+	}
+	if name == "" {
+		return r, fmt.Errorf("%s without name", kind.name)
+	}
+	return resource{kind: kind, name: name, spec: spec}, nil
+}
+
+// getObject decodes the object a GET request returns, nil when not found.
+func getObject(client *Client, path string) (map[string]interface{}, error) {
+	data, err := client.Request(goreq.GET, path, nil)
+	if e, ok := err.(*ResponseError); ok && e.Status == 404 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var v map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// listObjects lists the resources of the APIs returning them by name, such as pipelines and repositories.
+func listObjects(endpoint string) func(client *Client) (map[string]map[string]interface{}, error) {
+	return func(client *Client) (map[string]map[string]interface{}, error) {
+		resp, err := getObject(client, endpoint)
+		if err != nil {
+			return nil, err
+		}
+		objects := map[string]map[string]interface{}{}
+		for name, v := range resp {
+			spec, _ := v.(map[string]interface{})
+			objects[name] = spec
+		}
+		return objects, nil
+	}
+}
+
+func listTemplateResources(kind templateKind) func(client *Client) (map[string]map[string]interface{}, error) {
+	return func(client *Client) (map[string]map[string]interface{}, error) {
+		templates, err := listTemplates(client, kind, "")
+		if err != nil {
+			return nil, err
+		}
+		objects := map[string]map[string]interface{}{}
+		for _, t := range templates {
+			objects[t.name] = t.def
+		}
+		return objects, nil
+	}
+}
+
+// listPolicies returns the ILM policies as put, without their version and dates.
+func listPolicies(client *Client) (map[string]map[string]interface{}, error) {
+	policies, err := listObjects("_ilm/policy")(client)
+	if err != nil {
+		return nil, err
+	}
+	for name, p := range policies {
+		policies[name] = map[string]interface{}{"policy": p["policy"]}
+	}
+	return policies, nil
+}
+
+// listClusterSettings returns the persistent settings as the single resource named cluster.
+func listClusterSettings(client *Client) (map[string]map[string]interface{}, error) {
+	resp, err := getObject(client, "_cluster/settings?flat_settings=true")
+	if err != nil {
+		return nil, err
+	}
+	persistent, _ := resp["persistent"].(map[string]interface{})
+	return map[string]map[string]interface{}{"cluster": persistent}, nil
+}
+
+// listAliases returns the aliases of all indices by <alias>/<index>.
+func listAliases(client *Client) (map[string]map[string]interface{}, error) {
+	resp, err := getObject(client, "_alias")
+	if err != nil {
+		return nil, err
+	}
+	aliases := map[string]map[string]interface{}{}
+	for index, v := range resp {
+		v, _ := v.(map[string]interface{})
+		a, _ := v["aliases"].(map[string]interface{})
+		for alias, params := range a {
+			params, _ := params.(map[string]interface{})
+			aliases[alias+"/"+index] = params
+		}
+	}
+	return aliases, nil
+}
+
+// flattenAlias renders the parameters of an alias as the cluster returns them, routing being
+// split into index and search routing.
+func flattenAlias(params map[string]interface{}) map[string]string {
+	spec := map[string]interface{}{}
+	for k, v := range params {
+		switch {
+		case k == "routing":
+			spec["index_routing"], spec["search_routing"] = v, v
+		case v == false && (k == "is_write_index" || k == "is_hidden"):
+		default:
+			spec[k] = v
+		}
+	}
+	return flattenSpec(spec)
+}
+
+// flattenSpec renders a resource by dotted path for comparisons.
+func flattenSpec(spec map[string]interface{}) map[string]string {
+	return flattenObject(spec, nil)
+}
+
+// policyDefaults are the values the cluster adds to the ILM policies put, ignored by comparisons.
+var policyDefaults = map[string]string{
+	"min_age":                    "0ms",
+	"delete_searchable_snapshot": "true",
+}
+
+func flattenPolicy(spec map[string]interface{}) map[string]string {
+	flat := flattenSpec(spec)
+	var parents []string
+	for k, v := range flat {
+		i := strings.LastIndex(k, ".")
+		if policyDefaults[k[i+1:]] == v {
+			delete(flat, k)
+			if i > 0 {
+				parents = append(parents, k[:i])
+			}
+		}
+	}
+	// an action left with only defaults is kept as an empty object, as it is written in the spec
+	for _, parent := range parents {
+		empty := true
+		for k := range flat {
+			if strings.HasPrefix(k, parent+".") {
+				empty = false
+				break
+			}
+		}
+		if empty {
+			flat[parent] = "{}"
+		}
+	}
+	return flat
+}
+
+// managedResource tells whether the cluster manages a resource itself, e.g. the built-in templates
+// and policies of 8.x.
+func managedResource(spec map[string]interface{}) bool {
+	if policy, ok := spec["policy"].(map[string]interface{}); ok {
+		spec = policy
+	}
+	meta, _ := spec["_meta"].(map[string]interface{})
+	return meta["managed"] == true
+}
+
+// resourceChange is a resource to create, update or delete, and how it differs from the one of the cluster.
+type resourceChange struct {
+	resource
+	action string
+	diff   [][]interface{}
+}
+
+// planResources compares the resource files of dir to the cluster, returning the changes to make.
+func planResources(client *Client, dir string, prune bool) ([]resourceChange, error) {
+	resources, err := readResources(dir)
+	if err != nil {
+		return nil, err
+	}
+	var changes, deletes []resourceChange
+	for i := 0; i < len(resources); {
+		kind := resources[i].kind
+		declared := map[string]bool{}
+		aliases := map[string]bool{}
+		current, err := kind.list(client)
+		if err != nil {
+			return nil, err
+		}
+		for ; i < len(resources) && resources[i].kind == kind; i++ {
+			r := resources[i]
+			declared[r.name] = true
+			aliases[strings.SplitN(r.name, "/", 2)[0]] = true
+			spec, ok := current[r.name]
+			if !ok {
+				changes = append(changes, resourceChange{r, "create", nil})
+				continue
+			}
+			var diff [][]interface{}
+			for _, row := range diffValues(kind.name+" "+r.name, kind.flatten(spec), kind.flatten(r.spec), nil) {
+				if row[4] != "removed" || !kind.merged || prune {
+					diff = append(diff, row)
+				}
+			}
+			if len(diff) > 0 {
+				changes = append(changes, resourceChange{r, "update", diff})
+			}
+		}
+		if !prune || kind.merged {
+			continue
+		}
+		names := make([]string, 0, len(current))
+		for name := range current {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			switch {
+			case declared[name], strings.HasPrefix(name, "."), managedResource(current[name]), builtinResource(kind.name, name):
+			case kind.name == "alias" && !aliases[strings.SplitN(name, "/", 2)[0]]:
+				// only the indices of the declared aliases are managed
+			default:
+				deletes = append(deletes, resourceChange{resource{kind: kind, name: name, spec: current[name]}, "delete", nil})
+			}
+		}
+	}
+	if err := checkDeletes(client, changes, deletes); err != nil {
+		return nil, err
+	}
+	return append(changes, deletes...), nil
+}
+
+// builtinResources are the resources clusters before 8.0 create without marking them managed, by kind.
+var builtinResources = map[string][]string{
+	"ilm_policy": {
+		"ilm-history-ilm-policy", "slm-history-ilm-policy", "watch-history-ilm-policy", "ml-size-based-ilm-policy",
+		"logs", "metrics", "synthetics", "7-days-default", "30-days-default", "90-days-default", "180-days-default", "365-days-default",
+	},
+	"component_template": {"logs-mappings", "logs-settings", "metrics-mappings", "metrics-settings", "synthetics-mappings", "synthetics-settings"},
+	"index_template":     {"ilm-history", "slm-history", "logs", "metrics", "synthetics"},
+	"legacy_template":    {"ilm-history", "slm-history"},
+	"ingest_pipeline":    {"xpack_monitoring_6", "xpack_monitoring_7"},
+}
+
+func builtinResource(kind string, name string) bool {
+	for _, builtin := range builtinResources[kind] {
+		if name == builtin {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDeletes refuses the deletes the cluster would reject once the other changes are applied:
+// the ILM policies indices still use, and the component templates index templates are still
+// composed of.
+func checkDeletes(client *Client, changes []resourceChange, deletes []resourceChange) error {
+	var policies, components []string
+	for _, d := range deletes {
+		switch d.kind.name {
+		case "ilm_policy":
+			policies = append(policies, d.name)
+		case "component_template":
+			components = append(components, d.name)
+		}
+	}
+	var used map[string][]string
+	var err error
+	if len(policies) > 0 {
+		if used, err = policyIndices(client); err != nil {
+			return err
+		}
+		for _, name := range policies {
+			if indices := used[name]; len(indices) > 0 {
+				sort.Strings(indices)
+				return fmt.Errorf("cannot delete ilm_policy %s, used by %s; declare it or remove it from the indices first", name, abbreviate(indices))
+			}
+		}
+	}
+	if len(components) > 0 {
+		if used, err = componentTemplateUsers(client, append(changes, deletes...)); err != nil {
+			return err
+		}
+		for _, name := range components {
+			if templates := used[name]; len(templates) > 0 {
+				sort.Strings(templates)
+				return fmt.Errorf("cannot delete component_template %s, composing index_template %s; declare it or remove it from the templates first", name, strings.Join(templates, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// policyIndices returns the indices using each ILM policy, hidden and closed ones included.
+func policyIndices(client *Client) (map[string][]string, error) {
+	resp, err := getObject(client, "*/_settings/index.lifecycle.name?flat_settings=true&expand_wildcards=all")
+	if err != nil {
+		return nil, err
+	}
+	used := map[string][]string{}
+	for index, v := range resp {
+		v, _ := v.(map[string]interface{})
+		settings, _ := v["settings"].(map[string]interface{})
+		if policy := cell(settings["index.lifecycle.name"]); policy != "" {
+			used[policy] = append(used[policy], index)
+		}
+	}
+	return used, nil
+}
+
+// componentTemplateUsers returns the index templates composed of each component template, once
+// the changes are applied.
+func componentTemplateUsers(client *Client, changes []resourceChange) (map[string][]string, error) {
+	templates, err := listTemplateResources(templateKinds[1])(client)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range changes {
+		switch {
+		case c.kind.name != "index_template":
+		case c.action == "delete":
+			delete(templates, c.name)
+		default:
+			templates[c.name] = c.spec
+		}
+	}
+	used := map[string][]string{}
+	for name, def := range templates {
+		composedOf, _ := def["composed_of"].([]interface{})
+		for _, component := range composedOf {
+			used[cell(component)] = append(used[cell(component)], name)
+		}
+	}
+	return used, nil
+}
+
+// resourceChanges renders the changes, new and deleted resources in one row.
+func resourceChanges(changes []resourceChange) *Table {
+	t := &Table{Columns: []string{"resource", "key", "current", "desired", "change"}}
+	for _, c := range changes {
+		if c.diff == nil {
+			t.Rows = append(t.Rows, []interface{}{c.kind.name + " " + c.name, nil, nil, nil, c.action})
+			continue
+		}
+		t.Rows = append(t.Rows, c.diff...)
+	}
+	return t
+}
+
+func planSummary(changes []resourceChange) string {
+	count := map[string]int{}
+	for _, c := range changes {
+		count[c.action]++
+	}
+	return fmt.Sprintf("%d to create, %d to update, %d to delete", count["create"], count["update"], count["delete"])
+}
+
+// resourceRequest is a request applying changes.
+type resourceRequest struct {
+	method string
+	path   string
+	body   interface{}
+	label  string
+}
+
+// applyRequests returns the requests making changes: the puts in the order of resourceKinds, then
+// the deletes in reverse order. Aliases are changed by a single atomic request, so that a write
+// index can move from one index to another.
+func applyRequests(changes []resourceChange) []resourceRequest {
+	var puts, deletes []resourceRequest
+	var actions []interface{}
+	for _, c := range changes {
+		path := c.kind.endpoint + "/" + url.PathEscape(c.name)
+		switch {
+		case c.kind.name == "alias":
+			parts := strings.SplitN(c.name, "/", 2)
+			action := map[string]interface{}{"alias": parts[0], "index": parts[1]}
+			verb := "remove"
+			if c.action != "delete" {
+				verb = "add"
+				for k, v := range c.spec {
+					action[k] = v
+				}
+			}
+			actions = append(actions, map[string]interface{}{verb: action})
+		case c.kind.name == "cluster_settings":
+			settings := map[string]interface{}{}
+			for k, v := range c.spec {
+				settings[k] = v
+			}
+			// the settings pruned are reset to their default
+			for _, row := range c.diff {
+				if row[4] == "removed" {
+					settings[row[1].(string)] = nil
+				}
+			}
+			body := map[string]interface{}{"persistent": settings}
+			puts = append(puts, resourceRequest{goreq.PUT, c.kind.endpoint, body, "updated cluster settings"})
+		case c.action == "delete":
+			r := resourceRequest{goreq.DELETE, path, nil, fmt.Sprintf("deleted %s %s", c.kind.name, c.name)}
+			deletes = append([]resourceRequest{r}, deletes...)
+		default:
+			puts = append(puts, resourceRequest{goreq.PUT, path, c.spec, fmt.Sprintf("%sd %s %s", c.action, c.kind.name, c.name)})
+		}
+	}
+	if len(actions) > 0 {
+		body := map[string]interface{}{"actions": actions}
+		puts = append(puts, resourceRequest{goreq.POST, "_aliases", body, fmt.Sprintf("changed %d aliases", len(actions))})
+	}
+	return append(puts, deletes...)
+}
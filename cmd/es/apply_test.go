@@ -0,0 +1,160 @@
+package es
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFlattenSpec(t *testing.T) {
+	tests := []struct {
+		name string
+		spec map[string]interface{}
+		want map[string]string
+	}{
+		{
+			name: "pipeline",
+			spec: map[string]interface{}{
+				"description": "parse logs",
+				"processors":  []interface{}{map[string]interface{}{"set": map[string]interface{}{"field": "a", "value": "b"}}},
+				"on_failure":  []interface{}{},
+				"_meta":       map[string]interface{}{},
+				"version":     nil,
+			},
+			want: map[string]string{
+				"description": "parse logs",
+				"processors":  `[{"set":{"field":"a","value":"b"}}]`,
+			},
+		},
+		{
+			name: "nested empty objects",
+			spec: map[string]interface{}{"settings": map[string]interface{}{"client": "minio", "options": map[string]interface{}{}}},
+			want: map[string]string{"settings.client": "minio", "settings.options": "{}"},
+		},
+	}
+	for _, test := range tests {
+		if got := flattenSpec(test.spec); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: flattenSpec = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestFlattenAlias(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+		want   map[string]string
+	}{
+		{name: "no parameters", params: map[string]interface{}{}, want: map[string]string{}},
+		{
+			name:   "routing",
+			params: map[string]interface{}{"routing": "1", "is_write_index": true},
+			want:   map[string]string{"index_routing": "1", "search_routing": "1", "is_write_index": "true"},
+		},
+		{
+			name:   "defaults",
+			params: map[string]interface{}{"is_write_index": false, "is_hidden": false},
+			want:   map[string]string{},
+		},
+		{
+			name:   "filter",
+			params: map[string]interface{}{"filter": map[string]interface{}{"term": map[string]interface{}{"level": "error"}}},
+			want:   map[string]string{"filter.term.level": "error"},
+		},
+	}
+	for _, test := range tests {
+		if got := flattenAlias(test.params); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: flattenAlias = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestFlattenPolicy(t *testing.T) {
+	spec := map[string]interface{}{"policy": map[string]interface{}{"phases": map[string]interface{}{
+		"hot": map[string]interface{}{
+			"min_age": "0ms",
+			"actions": map[string]interface{}{"rollover": map[string]interface{}{"max_age": "1d"}},
+		},
+		"delete": map[string]interface{}{
+			"min_age": "30d",
+			"actions": map[string]interface{}{"delete": map[string]interface{}{"delete_searchable_snapshot": true}},
+		},
+	}}}
+	want := map[string]string{
+		"policy.phases.hot.actions.rollover.max_age": "1d",
+		"policy.phases.delete.min_age":               "30d",
+		"policy.phases.delete.actions.delete":        "{}",
+	}
+	if got := flattenPolicy(spec); !reflect.DeepEqual(got, want) {
+		t.Errorf("flattenPolicy = %v, want %v", got, want)
+	}
+}
+
+func TestManagedResource(t *testing.T) {
+	tests := []struct {
+		spec map[string]interface{}
+		want bool
+	}{
+		{map[string]interface{}{"_meta": map[string]interface{}{"managed": true}}, true},
+		{map[string]interface{}{"policy": map[string]interface{}{"_meta": map[string]interface{}{"managed": true}}}, true},
+		{map[string]interface{}{"_meta": map[string]interface{}{"managed": "true"}}, false},
+		{map[string]interface{}{"index_patterns": []interface{}{"logs-*"}}, false},
+	}
+	for _, test := range tests {
+		if got := managedResource(test.spec); got != test.want {
+			t.Errorf("managedResource(%v) = %v, want %v", test.spec, got, test.want)
+		}
+	}
+}
+
+func TestParseResource(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  map[string]interface{}
+		kind string
+		want string
+		spec map[string]interface{}
+		err  bool
+	}{
+		{
+			name: "pipeline",
+			doc:  map[string]interface{}{"kind": "ingest_pipeline", "name": "logs", "spec": map[string]interface{}{"processors": []interface{}{}}},
+			kind: "ingest_pipeline",
+			want: "logs",
+			spec: map[string]interface{}{"processors": []interface{}{}},
+		},
+		{
+			name: "alias",
+			doc:  map[string]interface{}{"kind": "alias", "name": "logs", "spec": map[string]interface{}{"index": "logs-1", "is_write_index": true}},
+			kind: "alias",
+			want: "logs/logs-1",
+			spec: map[string]interface{}{"is_write_index": true},
+		},
+		{
+			name: "cluster settings",
+			doc:  map[string]interface{}{"kind": "cluster_settings", "spec": map[string]interface{}{"persistent": map[string]interface{}{}}},
+			kind: "cluster_settings",
+			want: "cluster",
+			spec: map[string]interface{}{"persistent": map[string]interface{}{}},
+		},
+		{name: "unknown kind", doc: map[string]interface{}{"kind": "index", "name": "logs", "spec": map[string]interface{}{}}, err: true},
+		{name: "no spec", doc: map[string]interface{}{"kind": "ingest_pipeline", "name": "logs"}, err: true},
+		{name: "no name", doc: map[string]interface{}{"kind": "ingest_pipeline", "spec": map[string]interface{}{}}, err: true},
+		{name: "alias without index", doc: map[string]interface{}{"kind": "alias", "name": "logs", "spec": map[string]interface{}{}}, err: true},
+	}
+	for _, test := range tests {
+		r, err := parseResource(test.doc)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: parseResource succeeded, want an error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: parseResource: %v", test.name, err)
+			continue
+		}
+		if r.kind.name != test.kind || r.name != test.want || !reflect.DeepEqual(r.spec, test.spec) {
+			t.Errorf("%s: parseResource = %s %s %v, want %s %s %v", test.name, r.kind.name, r.name, r.spec, test.kind, test.want, test.spec)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package es
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// checkFlags are the options of the _cat commands run by monitoring, see addCheckFlags.
+var checkFlags struct {
+	check      bool
+	warn       []string
+	crit       []string
+	prometheus string
+}
+
+func addCheckFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&checkFlags.check, "check", false, "print a Nagios plugin line and exit 0 OK, 1 WARNING, 2 CRITICAL or 3 UNKNOWN")
+	cmd.Flags().StringArrayVar(&checkFlags.warn, "warn", nil, "with --check, WARNING when a row matches <column><op><value>, `rows` being the number of rows, repeatable")
+	cmd.Flags().StringArrayVar(&checkFlags.crit, "crit", nil, "with --check, CRITICAL when a row matches <column><op><value>, `rows` being the number of rows, repeatable")
+	cmd.Flags().StringVar(&checkFlags.prometheus, "prometheus", "", "write the rows as Prometheus metrics to - for stdout, or to a file in this textfile collector directory")
+}
+
+// nagiosStates are the Nagios names of the check statuses.
+var nagiosStates = [...]string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// plainUnits are the options of the _cat APIs rendering sizes or durations as plain numbers for the
+// thresholds and metrics, unless --bytes or --time are given; the other APIs reject them.
+var plainUnits = map[string]string{"allocation": "bytes=b", "pending_tasks": "time=ms"}
+
+// threshold is one --warn or --crit expression.
+type threshold struct {
+	expr   string
+	cond   *condition
+	status checkStatus
+}
+
+// checkCatCommand evaluates the --warn and --crit thresholds on a _cat API, and/or writes its rows
+// as Prometheus metrics. It exits with the Nagios status of the check, UNKNOWN for any error.
+func checkCatCommand(cluster string, api string, options ...string) {
+	service := strings.ToUpper(strings.SplitN(api, "/", 2)[0])
+	unknown := func(err error) {
+		if checkFlags.check {
+			fmt.Printf("%s UNKNOWN - %v\n", service, err)
+		} else {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+		os.Exit(int(statusUnknown))
+	}
+	thresholds, err := parseThresholds()
+	if err != nil {
+		unknown(err)
+	}
+	client, err := newClient(cluster)
+	if err != nil {
+		unknown(err)
+	}
+	if units, ok := plainUnits[api]; ok {
+		options = append(options, units)
+	}
+	table, err := catTable(client, api, options...)
+	if err != nil {
+		unknown(err)
+	}
+	status, summary, perfdata, err := evaluate(table, thresholds)
+	if err != nil {
+		unknown(err)
+	}
+	if checkFlags.prometheus != "" {
+		labels := client.cluster.metricLabels()
+		m := newMetrics()
+		prefix := "hebe_es_" + metricName(strings.SplitN(api, "/", 2)[0])
+		m.addTable(prefix, api, labels, table)
+		if checkFlags.check {
+			m.add("hebe_es_check_status", "status of hebe checks, 0 OK, 1 WARNING, 2 CRITICAL, 3 UNKNOWN", append(labels, label{"check", api}), float64(status))
+		}
+		if err := writeMetrics(m, checkFlags.prometheus, prefix+"_"+metricName(client.cluster.Name)); err != nil {
+			unknown(err)
+		}
+	}
+	if !checkFlags.check {
+		return
+	}
+	line := fmt.Sprintf("%s %s - %s", service, nagiosStates[status], summary)
+	if len(perfdata) > 0 {
+		line += " | " + strings.Join(perfdata, " ")
+	}
+	fmt.Println(line)
+	os.Exit(int(status))
+}
+
+func parseThresholds() ([]*threshold, error) {
+	var thresholds []*threshold
+	for _, t := range []struct {
+		exprs  []string
+		status checkStatus
+	}{{checkFlags.crit, statusCrit}, {checkFlags.warn, statusWarn}} {
+		for _, expr := range t.exprs {
+			c, err := parseCondition(expr)
+			if err != nil {
+				return nil, err
+			}
+			thresholds = append(thresholds, &threshold{expr: expr, cond: c, status: t.status})
+		}
+	}
+	return thresholds, nil
+}
+
+// evaluate grades a table against thresholds, returning a summary of the rows exceeding them
+// and the Nagios performance data of the columns they test.
+func evaluate(t *Table, thresholds []*threshold) (checkStatus, string, []string, error) {
+	index := map[string]int{}
+	for j, column := range t.Columns {
+		index[column] = j
+	}
+	keys := t.labelKeys()
+	status := statusOK
+	type hit struct {
+		status  checkStatus
+		message string
+	}
+	var hits []hit
+	var columns []string
+	// the warning and critical levels of the perfdata, for thresholds on values above a limit
+	levels := map[string]*[2]string{}
+	for _, th := range thresholds {
+		column := th.cond.column
+		j, ok := index[column]
+		if !ok && column != "rows" && len(t.Rows) > 0 {
+			return statusUnknown, "", nil, fmt.Errorf("unknown column %q in --warn or --crit, available: rows,%s", column, strings.Join(t.Columns, ","))
+		}
+		if levels[column] == nil {
+			levels[column] = &[2]string{}
+			columns = append(columns, column)
+		}
+		level := 0
+		if th.status == statusCrit {
+			level = 1
+		}
+		if (th.cond.op == ">" || th.cond.op == ">=") && levels[column][level] == "" {
+			levels[column][level] = th.cond.value
+		}
+
+		var matched []string
+		if ok {
+			for i, row := range t.Rows {
+				if v := cell(row[j]); th.cond.match(v) {
+					matched = append(matched, keys[i]+"="+v)
+				}
+			}
+		} else if n := strconv.Itoa(len(t.Rows)); column == "rows" && th.cond.match(n) {
+			matched = append(matched, n)
+		}
+		if len(matched) > 0 {
+			status = worse(status, th.status)
+			hits = append(hits, hit{th.status, th.expr + ": " + abbreviate(matched)})
+		}
+	}
+	// only the thresholds of the worst status are reported
+	var exceeded []string
+	for _, h := range hits {
+		if h.status == status {
+			exceeded = append(exceeded, h.message)
+		}
+	}
+
+	summary := "no threshold exceeded"
+	if len(exceeded) > 0 {
+		summary = strings.Join(exceeded, "; ")
+	}
+	var perfdata []string
+	for _, column := range columns {
+		l := *levels[column]
+		j, ok := index[column]
+		if !ok {
+			if column == "rows" {
+				perfdata = append(perfdata, fmt.Sprintf("'rows'=%d;%s;%s", len(t.Rows), l[0], l[1]))
+			}
+			continue
+		}
+		for i, row := range t.Rows {
+			v := strings.TrimSuffix(cell(row[j]), "%")
+			if _, err := strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+			name := column
+			if len(t.Rows) > 1 {
+				name = keys[i] + "/" + column
+			}
+			perfdata = append(perfdata, fmt.Sprintf("'%s'=%s;%s;%s", name, v, l[0], l[1]))
+		}
+	}
+	return status, summary, perfdata, nil
+}
+
+// labelKeys names each row by its non numeric cells, e.g. node/thread pool, or by its position.
+func (t *Table) labelKeys() []string {
+	var labelColumns []int
+	for j := range t.Columns {
+		if !t.numeric(j) && !t.health(j) {
+			labelColumns = append(labelColumns, j)
+		}
+	}
+	keys := make([]string, len(t.Rows))
+	for i, row := range t.Rows {
+		var parts []string
+		for _, j := range labelColumns {
+			if v := cell(row[j]); v != "" {
+				parts = append(parts, v)
+			}
+		}
+		keys[i] = strings.Join(parts, "/")
+		if keys[i] == "" {
+			keys[i] = fmt.Sprintf("#%d", i)
+		}
+	}
+	return keys
+}
+
+// writeMetrics writes metrics to stdout for -, or replaces name.prom in a textfile collector directory.
+func writeMetrics(m *metrics, target string, name string) error {
+	if target == "-" {
+		return m.write(os.Stdout)
+	}
+	// the collector must never read a partial file, so write aside and rename
+	f, err := ioutil.TempFile(target, "."+name+".prom.")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := m.write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(target, name+".prom"))
+}
@@ -0,0 +1,127 @@
+package es
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	health := &Table{
+		Columns: []string{"cluster", "status", "unassign"},
+		Rows:    [][]interface{}{{"logs", "yellow", "2"}},
+	}
+	threads := &Table{
+		Columns: []string{"node_name", "name", "queue", "rejected"},
+		Rows: [][]interface{}{
+			{"n1", "write", "0", "0"},
+			{"n2", "search", "7", "15"},
+		},
+	}
+	allocation := &Table{
+		Columns: []string{"node", "disk.percent"},
+		Rows:    [][]interface{}{{"n1", "70%"}, {"n2", "91%"}, {"UNASSIGNED", nil}},
+	}
+	tests := []struct {
+		name     string
+		table    *Table
+		crit     []string
+		warn     []string
+		status   checkStatus
+		summary  string
+		perfdata []string
+		err      bool
+	}{
+		{
+			name:     "ok",
+			table:    health,
+			crit:     []string{"status=red"},
+			warn:     []string{"unassign>5"},
+			status:   statusOK,
+			summary:  "no threshold exceeded",
+			perfdata: []string{"'unassign'=2;5;"},
+		},
+		{
+			name:     "warning",
+			table:    health,
+			crit:     []string{"status=red"},
+			warn:     []string{"status=yellow", "unassign>0"},
+			status:   statusWarn,
+			summary:  "status=yellow: logs=yellow; unassign>0: logs=2",
+			perfdata: []string{"'unassign'=2;0;"},
+		},
+		{
+			name:    "only the worst status is reported",
+			table:   threads,
+			crit:    []string{"rejected>10"},
+			warn:    []string{"queue>=5"},
+			status:  statusCrit,
+			summary: "rejected>10: n2/search=15",
+			perfdata: []string{
+				"'n1/write/rejected'=0;;10", "'n2/search/rejected'=15;;10",
+				"'n1/write/queue'=0;5;", "'n2/search/queue'=7;5;",
+			},
+		},
+		{
+			name:     "percentages",
+			table:    allocation,
+			crit:     []string{"disk.percent>=90"},
+			status:   statusCrit,
+			summary:  "disk.percent>=90: n2=91%",
+			perfdata: []string{"'n1/disk.percent'=70;;90", "'n2/disk.percent'=91;;90"},
+		},
+		{
+			name:     "rows",
+			table:    &Table{Columns: []string{"insertOrder", "timeInQueue"}},
+			warn:     []string{"rows>0"},
+			status:   statusOK,
+			summary:  "no threshold exceeded",
+			perfdata: []string{"'rows'=0;0;"},
+		},
+		{
+			name:     "rows exceeded",
+			table:    threads,
+			crit:     []string{"rows>=2"},
+			status:   statusCrit,
+			summary:  "rows>=2: 2",
+			perfdata: []string{"'rows'=2;;2"},
+		},
+		{
+			name:  "unknown column",
+			table: threads,
+			warn:  []string{"size>1"},
+			err:   true,
+		},
+	}
+	for _, test := range tests {
+		var thresholds []*threshold
+		for _, th := range []struct {
+			exprs  []string
+			status checkStatus
+		}{{test.crit, statusCrit}, {test.warn, statusWarn}} {
+			for _, expr := range th.exprs {
+				c, err := parseCondition(expr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				thresholds = append(thresholds, &threshold{expr: expr, cond: c, status: th.status})
+			}
+		}
+		status, summary, perfdata, err := evaluate(test.table, thresholds)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: evaluate succeeded, want an error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: evaluate: %v", test.name, err)
+			continue
+		}
+		if status != test.status || summary != test.summary {
+			t.Errorf("%s: evaluate = %v %q, want %v %q", test.name, status, summary, test.status, test.summary)
+		}
+		if !reflect.DeepEqual(perfdata, test.perfdata) {
+			t.Errorf("%s: perfdata %q, want %q", test.name, perfdata, test.perfdata)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package es
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hebe/langs/goreq"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+const (
+	// dialTimeout bounds connecting to a host, for requests to go on to the next one
+	// when a host is down without refusing connections.
+	dialTimeout         = 5 * time.Second
+	tlsHandshakeTimeout = 10 * time.Second
+)
+
+// Client sends requests to one cluster, sharing a transport between requests.
+// Requests failing to connect are retried against the other hosts of the cluster.
+type Client struct {
+	cluster   *Cluster
+	transport *http.Transport
+	hosts     *hostPool
+
+	mu        sync.Mutex
+	confirmed bool // the production cluster name was typed
+}
+
+func newClient(name string) (*Client, error) {
+	cluster, err := loadCluster(name)
+	if err != nil {
+		return nil, err
+	}
+	cluster.override(&flagCluster)
+	config, err := tlsConfig(cluster)
+	if err != nil {
+		return nil, err
+	}
+	urls := make([]string, len(cluster.Hosts))
+	for i, h := range cluster.Hosts {
+		urls[i] = cluster.baseURL(h)
+	}
+	c := &Client{
+		cluster: cluster,
+		transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			DialContext:         (&net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}).DialContext,
+			TLSClientConfig:     config,
+			TLSHandshakeTimeout: tlsHandshakeTimeout,
+		},
+		hosts: newHostPool(urls),
+	}
+	if cluster.Sniff {
+		if err := c.sniff(); err != nil {
+			return nil, fmt.Errorf("sniff nodes: %v", err)
+		}
+	}
+	return c, nil
+}
+
+// agent returns a SuperAgent prepared for method and path on h, with the profile's headers and credentials.
+func (c *Client) agent(h *host, method string, path string) *goreq.SuperAgent {
+	r := goreq.New()
+	r.Transport = c.transport
+	r.CustomMethod(method, h.url+"/"+strings.TrimPrefix(path, "/"))
+	for k, v := range c.cluster.Headers {
+		r.Set(k, v)
+	}
+	switch {
+	case c.cluster.APIKey != "":
+		r.Set("Authorization", "ApiKey "+encodeAPIKey(c.cluster.APIKey))
+	case c.cluster.Token != "":
+		r.Set("Authorization", "Bearer "+c.cluster.Token)
+	case c.cluster.Username != "":
+		r.SetBasicAuth(c.cluster.Username, c.cluster.Password)
+	}
+	return r
+}
+
+// Request sends a request and returns the response body. body is sent as is when it is a
+// string, or marshalled as JSON otherwise. Connection failures are returned as
+// ConnectionError and error statuses as ResponseError.
+func (c *Client) Request(method string, path string, body interface{}) ([]byte, error) {
+	stream, err := c.Stream(method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	return ioutil.ReadAll(stream)
+}
+
+// Stream sends a request like Request, but returns the response body unread, for
+// reading large responses as they arrive. The caller must close it.
+func (c *Client) Stream(method string, path string, body interface{}) (io.ReadCloser, error) {
+	var content string
+	switch b := body.(type) {
+	case nil:
+	case string:
+		content = b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return nil, err
+		}
+		content = string(data)
+	}
+	resp, err := c.do(method, path, body, func(r *goreq.SuperAgent) {
+		if body != nil {
+			r.Send(content)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Bulk posts a newline delimited JSON body to path, e.g. index/_bulk.
+func (c *Client) Bulk(path string, body string) ([]byte, error) {
+	resp, err := c.do(goreq.POST, path, body, func(r *goreq.SuperAgent) {
+		r.Type("ndjson").Send(body)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
+
+// do sends a request with body prepared by send once the guardrails of the cluster allow it,
+// recording it in the audit log when it changes the cluster.
+func (c *Client) do(method string, path string, body interface{}, send func(r *goreq.SuperAgent)) (goreq.Response, error) {
+	if err := c.guard(method, path, body); err != nil {
+		return nil, err
+	}
+	resp, err := c.roundTrip(method, path, send)
+	if mutating(method, path) {
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		} else if e, ok := err.(*ResponseError); ok {
+			status = e.Status
+		}
+		c.audit(method, path, status)
+	}
+	return resp, err
+}
+
+// roundTrip sends a request prepared by send, trying each host of the cluster in turn
+// until one of them responds. The body of a successful response is left unread.
+// Requests other than GET and HEAD only go to the next host when the connection failed,
+// as a request failing later may have been applied already.
+func (c *Client) roundTrip(method string, path string, send func(r *goreq.SuperAgent)) (goreq.Response, error) {
+	var err error
+	for attempt := 0; attempt < c.hosts.size(); attempt++ {
+		h := c.hosts.pick()
+		r := c.agent(h, method, path)
+		send(r)
+		resp, errs := r.EndStream()
+		if len(errs) > 0 {
+			err = errs[0]
+			if e, ok := err.(*url.Error); ok {
+				err = e.Err
+			}
+			c.hosts.markDead(h)
+			retry := method == goreq.GET || method == goreq.HEAD || isDialError(err)
+			err = &ConnectionError{URL: h.url, Err: err}
+			if !retry {
+				return nil, err
+			}
+			continue
+		}
+		c.hosts.markAlive(h)
+		if resp.StatusCode >= 400 {
+			data, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			return nil, newResponseError(resp.StatusCode, data)
+		}
+		return resp, nil
+	}
+	return nil, err
+}
+
+// isDialError tells whether err happened connecting to a host, before any request was sent.
+func isDialError(err error) bool {
+	e, ok := err.(*net.OpError)
+	return ok && e.Op == "dial"
+}
+
+// flavor tells whether the cluster is elasticsearch or opensearch, from the distribution its root endpoint reports.
+func (c *Client) flavor() (string, error) {
+	flavor, _, err := c.version()
+	return flavor, err
+}
+
+// version returns the flavor of the cluster and its version number, from its root endpoint.
+func (c *Client) version() (string, string, error) {
+	data, err := c.Request(goreq.GET, "", nil)
+	if err != nil {
+		return "", "", err
+	}
+	var resp struct {
+		Version struct {
+			Number       string `json:"number"`
+			Distribution string `json:"distribution"`
+		} `json:"version"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return "", "", err
+	}
+	if resp.Version.Distribution == "opensearch" {
+		return "opensearch", resp.Version.Number, nil
+	}
+	return "elasticsearch", resp.Version.Number, nil
+}
+
+// encodeAPIKey accepts either the encoded key or the `id:api_key` pair returned by the create API key API.
+func encodeAPIKey(key string) string {
+	if strings.Contains(key, ":") {
+		return base64.StdEncoding.EncodeToString([]byte(key))
+	}
+	return key
+}
+
+func tlsConfig(c *Cluster) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: c.Insecure}
+	if c.CACert != "" {
+		pem, err := readFile(c.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("read ca_cert: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.CACert)
+		}
+		config.RootCAs = pool
+	}
+	if c.ClientCert != "" || c.ClientKey != "" {
+		certFile, err := homedir.Expand(c.ClientCert)
+		if err != nil {
+			return nil, err
+		}
+		keyFile, err := homedir.Expand(c.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+func readFile(name string) ([]byte, error) {
+	path, err := homedir.Expand(name)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(path)
+}
@@ -0,0 +1,61 @@
+package es
+
+import (
+	"hebe/langs/goreq"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRoundTripFailover(t *testing.T) {
+	// a host reading requests and closing the connection without answering
+	var dropped int32
+	drop := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&dropped, 1)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer drop.Close()
+	var served int32
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&served, 1)
+		w.Write([]byte("{}"))
+	}))
+	defer ok.Close()
+	// a port nothing listens on
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refused := "http://" + l.Addr().String()
+	l.Close()
+
+	tests := []struct {
+		name    string
+		method  string
+		hosts   []string
+		dropped int32
+		served  int32
+		err     bool
+	}{
+		{name: "read after a dropped connection", method: goreq.GET, hosts: []string{drop.URL, ok.URL}, dropped: 1, served: 1},
+		{name: "write after a dropped connection", method: goreq.POST, hosts: []string{drop.URL, ok.URL}, dropped: 1, err: true},
+		{name: "write after a refused connection", method: goreq.POST, hosts: []string{refused, ok.URL}, served: 1},
+	}
+	for _, test := range tests {
+		atomic.StoreInt32(&dropped, 0)
+		atomic.StoreInt32(&served, 0)
+		c := &Client{cluster: &Cluster{Name: "test"}, transport: &http.Transport{DisableKeepAlives: true}, hosts: newHostPool(test.hosts)}
+		_, err := c.Request(test.method, "logs/_doc", map[string]interface{}{"n": 1})
+		if (err != nil) != test.err {
+			t.Errorf("%s: Request error %v, want an error %v", test.name, err, test.err)
+		}
+		if d, s := atomic.LoadInt32(&dropped), atomic.LoadInt32(&served); d != test.dropped || s != test.served {
+			t.Errorf("%s: %d dropped and %d served, want %d and %d", test.name, d, s, test.dropped, test.served)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+/*
+Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package es
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// clustersCmd represents the es command
+var clustersCmd = &cobra.Command{
+	Use:   "clusters",
+	Short: "List cluster profiles from config",
+	Long: `List the cluster profiles defined in the clusters section of the config file.
+
+Any profile name can be passed to --cluster in place of a host, e.g.
+
+  hebe es health -c prod-logs`,
+	Run: func(cmd *cobra.Command, args []string) {
+		profiles, err := loadProfiles()
+		check(err)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+		fmt.Fprintln(w, "name\tscheme\thosts")
+		for _, name := range profileNames(profiles) {
+			c := profiles[name]
+			fmt.Fprintf(w, "%s\t%s\t%s\n", name, c.scheme(), strings.Join(c.Hosts, ","))
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	EsCmd.AddCommand(clustersCmd)
+}
@@ -0,0 +1,150 @@
+package es
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Cluster is a cluster profile from the `clusters` section of the config file, e.g.
+//
+//	clusters:
+//	  prod-logs:
+//	    hosts: [es1.example.com:9200, es2.example.com:9200]
+//	    scheme: https
+//	    username: elastic
+//	    password: changeme
+//	    ca_cert: ~/.hebe/prod-ca.pem
+//	    sniff: true
+//	    headers:
+//	      X-Opaque-Id: hebe
+//	    tags: [production]
+//	    read_only: false
+//	    protected_indices: [".security*", "billing-*"]
+//	    labels:
+//	      team: search
+//
+// A --cluster value which is not a profile name is used as a comma separated list of hosts. Hosts may
+// carry their own scheme (https://es1:9200); otherwise the profile scheme is used,
+// defaulting to https when any TLS setting is present and http elsewise.
+type Cluster struct {
+	Name       string
+	Hosts      []string          `mapstructure:"hosts"`
+	Scheme     string            `mapstructure:"scheme"`
+	Username   string            `mapstructure:"username"`
+	Password   string            `mapstructure:"password"`
+	APIKey     string            `mapstructure:"api_key"`
+	Token      string            `mapstructure:"token"`
+	CACert     string            `mapstructure:"ca_cert"`
+	ClientCert string            `mapstructure:"client_cert"`
+	ClientKey  string            `mapstructure:"client_key"`
+	Insecure   bool              `mapstructure:"insecure"`
+	Headers    map[string]string `mapstructure:"headers"`
+	Sniff      bool              `mapstructure:"sniff"`
+
+	// guardrails, checked before each request changing the cluster
+	Tags             []string `mapstructure:"tags"`
+	ReadOnly         bool     `mapstructure:"read_only"`
+	ProtectedIndices []string `mapstructure:"protected_indices"`
+
+	// Prometheus labels of the metrics of the cluster, besides its name
+	Labels map[string]string `mapstructure:"labels"`
+}
+
+func loadProfiles() (map[string]*Cluster, error) {
+	profiles := map[string]*Cluster{}
+	if err := viper.UnmarshalKey("clusters", &profiles); err != nil {
+		return nil, fmt.Errorf("invalid clusters config: %v", err)
+	}
+	for name, c := range profiles {
+		c.Name = name
+	}
+	return profiles, nil
+}
+
+// loadCluster resolves a --cluster value to a profile, falling back to a raw host.
+func loadCluster(name string) (*Cluster, error) {
+	profiles, err := loadProfiles()
+	if err != nil {
+		return nil, err
+	}
+	// viper lower-cases all keys
+	if c, ok := profiles[strings.ToLower(name)]; ok {
+		if len(c.Hosts) == 0 {
+			return nil, fmt.Errorf("cluster %q has no hosts", name)
+		}
+		return c, nil
+	}
+	return &Cluster{Name: name, Hosts: strings.Split(name, ",")}, nil
+}
+
+// override replaces fields of c with the non-empty fields of o.
+func (c *Cluster) override(o *Cluster) {
+	if o.Scheme != "" {
+		c.Scheme = o.Scheme
+	}
+	if o.Username != "" {
+		c.Username = o.Username
+	}
+	if o.Password != "" {
+		c.Password = o.Password
+	}
+	if o.APIKey != "" {
+		c.APIKey = o.APIKey
+	}
+	if o.Token != "" {
+		c.Token = o.Token
+	}
+	if o.CACert != "" {
+		c.CACert = o.CACert
+	}
+	if o.ClientCert != "" {
+		c.ClientCert, c.ClientKey = o.ClientCert, o.ClientKey
+	}
+	if o.Insecure {
+		c.Insecure = true
+	}
+	if o.Sniff {
+		c.Sniff = true
+	}
+}
+
+func (c *Cluster) scheme() string {
+	switch {
+	case c.Scheme != "":
+		return strings.ToLower(c.Scheme)
+	case c.CACert != "" || c.ClientCert != "" || c.Insecure:
+		return "https"
+	default:
+		return "http"
+	}
+}
+
+// baseURL returns scheme://host for one of the cluster hosts.
+func (c *Cluster) baseURL(host string) string {
+	host = strings.TrimSuffix(host, "/")
+	if strings.Contains(host, "://") {
+		return host
+	}
+	return c.scheme() + "://" + host
+}
+
+func (c *Cluster) hasTag(tag string) bool {
+	for _, t := range c.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func profileNames(profiles map[string]*Cluster) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
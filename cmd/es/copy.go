@@ -0,0 +1,404 @@
+/*
+Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package es
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hebe/langs/goreq"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// copyCmd represents the es command
+var copyCmd = &cobra.Command{
+	Use:   "copy",
+	Short: "Copy documents between indices, on one cluster or across clusters",
+	Long: `Copy the documents of an index, or those matching a query, to another index of
+the same or of another cluster. Both ends are given as [cluster:]index, the cluster
+being a profile or hosts, and --cluster by default, e.g.
+
+  hebe es copy --from prod:logs-2026.10 --to staging:logs-copy --mappings --settings
+  hebe es copy --from logs-2026.10 --to logs-2026.10-fixed -q 'NOT tags:broken'
+
+On one cluster the copy runs the _reindex API, and across clusters a remote reindex
+from --remote-host, the first host of the source by default. Both are polled until the
+task completes, and interrupting the command cancels the task. When the destination
+does not allow remote reindex from the source (reindex.remote.whitelist), documents
+are streamed through hebe instead, which --mode stream forces.
+
+--mappings, --settings and --aliases first create the destination index like the
+source index.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		from, err := cmd.Flags().GetString("from")
+		check(err)
+		to, err := cmd.Flags().GetString("to")
+		check(err)
+		mode, err := cmd.Flags().GetString("mode")
+		check(err)
+		remoteHost, err := cmd.Flags().GetString("remote-host")
+		check(err)
+		mappings, err := cmd.Flags().GetBool("mappings")
+		check(err)
+		settings, err := cmd.Flags().GetBool("settings")
+		check(err)
+		aliases, err := cmd.Flags().GetBool("aliases")
+		check(err)
+		slices, err := cmd.Flags().GetInt("slices")
+		check(err)
+		pageSize, err := cmd.Flags().GetInt("page-size")
+		check(err)
+		workers, err := cmd.Flags().GetInt("workers")
+		check(err)
+
+		if from == "" || to == "" {
+			check(errors.New("--from and --to are required"))
+		}
+		if mode != "auto" && mode != "reindex" && mode != "stream" {
+			check(fmt.Errorf("invalid --mode %q, expected auto, reindex or stream", mode))
+		}
+		srcCluster, srcIndex := splitIndex(from, cluster)
+		dstCluster, dstIndex := splitIndex(to, cluster)
+		src, err := newClient(srcCluster)
+		check(err)
+		dst := src
+		if dstCluster != srcCluster {
+			dst, err = newClient(dstCluster)
+			check(err)
+		}
+		body, err := queryBody()
+		check(err)
+
+		if mappings || settings || aliases {
+			check(createLike(src, srcIndex, dst, dstIndex, mappings, settings, aliases))
+			fmt.Fprintf(os.Stderr, "created index %s\n", dstIndex)
+		}
+
+		c := &copier{src: src, srcIndex: srcIndex, dst: dst, dstIndex: dstIndex, query: body["query"]}
+		if mode != "stream" {
+			var remote map[string]interface{}
+			if dst != src {
+				remote = remoteSource(src, remoteHost)
+			}
+			err = c.reindex(remote, slices, pageSize)
+			e, ok := err.(*ResponseError)
+			if mode == "reindex" || !ok || !strings.Contains(e.Reason, "whitelist") && !strings.Contains(e.Reason, "allowlist") {
+				check(err)
+				return
+			}
+			fmt.Fprintf(os.Stderr, "%s, copying through hebe\n", e.Reason)
+		}
+		check(c.stream(slices, pageSize, workers))
+	},
+}
+
+func init() {
+	EsCmd.AddCommand(copyCmd)
+	addQueryFlags(copyCmd)
+
+	copyCmd.Flags().String("from", "", "source [cluster:]index")
+	copyCmd.Flags().String("to", "", "destination [cluster:]index")
+	copyCmd.Flags().String("mode", "auto", "auto, reindex to only use the _reindex API, or stream to copy through hebe")
+	copyCmd.Flags().String("remote-host", "", "URL of the source cluster as seen by the destination, for remote reindex")
+	copyCmd.Flags().Bool("mappings", false, "create the destination index with the mappings of the source index")
+	copyCmd.Flags().Bool("settings", false, "create the destination index with the settings of the source index")
+	copyCmd.Flags().Bool("aliases", false, "create the destination index with the aliases of the source index")
+	copyCmd.Flags().Int("slices", 1, "number of parallel slices")
+	copyCmd.Flags().Int("page-size", 1000, "number of documents per batch")
+	copyCmd.Flags().Int("workers", 2, "number of concurrent bulk requests when streaming")
+}
+
+// splitIndex splits [cluster:]index, index names cannot contain colons.
+func splitIndex(spec string, cluster string) (string, string) {
+	if i := strings.LastIndex(spec, ":"); i >= 0 {
+		return spec[:i], spec[i+1:]
+	}
+	return cluster, spec
+}
+
+// internalSettings are the flat settings of an index which cannot be set on a new index.
+var internalSettings = []string{
+	"index.uuid",
+	"index.version.",
+	"index.creation_date",
+	"index.provided_name",
+	"index.history.uuid",
+	"index.resize.",
+	"index.routing.allocation.initial_recovery.",
+	"index.verified_before_close",
+}
+
+// creatableSettings returns flat index settings without the internal ones.
+func creatableSettings(settings map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	for k, v := range settings {
+		internal := false
+		for _, prefix := range internalSettings {
+			if k == prefix || strings.HasSuffix(prefix, ".") && strings.HasPrefix(k, prefix) {
+				internal = true
+				break
+			}
+		}
+		if !internal {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// createLike creates the index dstIndex with the mappings, settings and/or aliases of srcIndex.
+func createLike(src *Client, srcIndex string, dst *Client, dstIndex string, mappings, settings, aliases bool) error {
+	data, err := src.Request(goreq.GET, srcIndex+"?flat_settings=true", nil)
+	if err != nil {
+		return err
+	}
+	var indices map[string]struct {
+		Aliases  map[string]interface{} `json:"aliases"`
+		Mappings map[string]interface{} `json:"mappings"`
+		Settings map[string]interface{} `json:"settings"`
+	}
+	if err := json.Unmarshal(data, &indices); err != nil {
+		return err
+	}
+	if len(indices) != 1 {
+		return fmt.Errorf("%s matches %d indices, the index to create from must be a single one", srcIndex, len(indices))
+	}
+	body := map[string]interface{}{}
+	for _, index := range indices {
+		if mappings {
+			body["mappings"] = index.Mappings
+		}
+		if settings {
+			body["settings"] = creatableSettings(index.Settings)
+		}
+		if aliases {
+			body["aliases"] = index.Aliases
+		}
+	}
+	_, err = dst.Request(goreq.PUT, dstIndex, body)
+	return err
+}
+
+// remoteSource returns the source.remote of a reindex from the cluster of c.
+func remoteSource(c *Client, host string) map[string]interface{} {
+	if host == "" {
+		host = c.cluster.baseURL(c.cluster.Hosts[0])
+	}
+	remote := map[string]interface{}{"host": host}
+	headers := map[string]string{}
+	for k, v := range c.cluster.Headers {
+		headers[k] = v
+	}
+	switch {
+	case c.cluster.APIKey != "":
+		headers["Authorization"] = "ApiKey " + encodeAPIKey(c.cluster.APIKey)
+	case c.cluster.Token != "":
+		headers["Authorization"] = "Bearer " + c.cluster.Token
+	case c.cluster.Username != "":
+		remote["username"] = c.cluster.Username
+		remote["password"] = c.cluster.Password
+	}
+	if len(headers) > 0 {
+		remote["headers"] = headers
+	}
+	return remote
+}
+
+// copier copies the documents of srcIndex matching query to dstIndex.
+type copier struct {
+	src      *Client
+	srcIndex string
+	dst      *Client
+	dstIndex string
+	query    interface{}
+}
+
+type reindexStatus struct {
+	Total            int64 `json:"total"`
+	Created          int64 `json:"created"`
+	Updated          int64 `json:"updated"`
+	Deleted          int64 `json:"deleted"`
+	VersionConflicts int64 `json:"version_conflicts"`
+}
+
+// reindex runs a _reindex task on the destination cluster, from remote when it is not nil,
+// and waits for it to complete.
+func (c *copier) reindex(remote map[string]interface{}, slices int, pageSize int) error {
+	source := map[string]interface{}{"index": c.srcIndex, "size": pageSize}
+	if c.query != nil {
+		source["query"] = c.query
+	}
+	// the destination index is in the body
+	if err := c.dst.guard(goreq.POST, c.dstIndex, nil); err != nil {
+		return err
+	}
+	path := "_reindex?wait_for_completion=false"
+	if remote != nil {
+		source["remote"] = remote
+	} else if slices > 1 {
+		// remote reindex cannot be sliced
+		path += fmt.Sprintf("&slices=%d", slices)
+	}
+	data, err := c.dst.Request(goreq.POST, path, map[string]interface{}{
+		"source": source,
+		"dest":   map[string]interface{}{"index": c.dstIndex},
+	})
+	if err != nil {
+		return err
+	}
+	var started struct {
+		Task string `json:"task"`
+	}
+	if err := json.Unmarshal(data, &started); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "reindex task %s\n", started.Task)
+
+	var p progress
+	p.total = -1
+	p.start(2 * time.Second)
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-interrupt:
+			p.stop()
+			c.dst.Request(goreq.POST, "_tasks/"+started.Task+"/_cancel", nil)
+			return fmt.Errorf("cancelled reindex task %s", started.Task)
+		case <-ticker.C:
+		}
+		data, err := c.dst.Request(goreq.GET, "_tasks/"+started.Task, nil)
+		if err != nil {
+			p.stop()
+			return err
+		}
+		var task struct {
+			Completed bool `json:"completed"`
+			Task      struct {
+				Status reindexStatus `json:"status"`
+			} `json:"task"`
+			Response struct {
+				reindexStatus
+				Failures []json.RawMessage `json:"failures"`
+			} `json:"response"`
+			Error json.RawMessage `json:"error"`
+		}
+		if err := json.Unmarshal(data, &task); err != nil {
+			p.stop()
+			return err
+		}
+		status := task.Task.Status
+		p.setTotal(status.Total)
+		p.set(status.Created + status.Updated + status.Deleted)
+		if !task.Completed {
+			continue
+		}
+		p.stop()
+		if task.Error != nil {
+			return newResponseError(500, data)
+		}
+		status = task.Response.reindexStatus
+		fmt.Fprintf(os.Stderr, "created %d, updated %d, version conflicts %d\n", status.Created, status.Updated, status.VersionConflicts)
+		if failures := task.Response.Failures; len(failures) > 0 {
+			for i, f := range failures {
+				if i == 10 {
+					fmt.Fprintf(os.Stderr, "...\n")
+					break
+				}
+				fmt.Fprintf(os.Stderr, "%s\n", f)
+			}
+			return fmt.Errorf("%d documents failed", len(failures))
+		}
+		return nil
+	}
+}
+
+// errCopyStopped stops the search of a streamed copy when the bulk requests failed.
+var errCopyStopped = errors.New("copy stopped")
+
+// docPipe passes the hits of a dumper to a loader.
+type docPipe struct {
+	docs chan *bulkDoc
+	done <-chan struct{}
+}
+
+func (p *docPipe) write(h *hit) error {
+	source, err := json.Marshal(h.Source)
+	if err != nil {
+		return err
+	}
+	select {
+	case p.docs <- &bulkDoc{id: h.ID, source: source}:
+		return nil
+	case <-p.done:
+		return errCopyStopped
+	}
+}
+
+func (p *docPipe) close() error {
+	close(p.docs)
+	return nil
+}
+
+func (p *docPipe) read() (*bulkDoc, error) {
+	doc, ok := <-p.docs
+	if !ok {
+		return nil, io.EOF
+	}
+	return doc, nil
+}
+
+// stream searches the source documents and indexes them into the destination with bulk requests.
+func (c *copier) stream(slices int, pageSize int, workers int) error {
+	body := map[string]interface{}{}
+	if c.query != nil {
+		body["query"] = c.query
+	}
+	d := &dumper{client: c.src, index: c.srcIndex, body: body, pageSize: pageSize}
+	l := &loader{client: c.dst, index: c.dstIndex, op: "index", retries: 5, done: make(chan struct{})}
+	pipe := &docPipe{docs: make(chan *bulkDoc, pageSize), done: l.done}
+
+	searched := make(chan error, 1)
+	go func() {
+		err := d.run(pipe, slices, false)
+		pipe.close()
+		searched <- err
+	}()
+	l.progress.total = d.count()
+	l.progress.start(2 * time.Second)
+	err := l.run(pipe, workers, pageSize, 5<<20)
+	l.progress.stop()
+	if err != nil {
+		return err
+	}
+	if err := <-searched; err != nil {
+		return err
+	}
+	if l.failed > 0 {
+		return fmt.Errorf("%d documents failed", l.failed)
+	}
+	return nil
+}
@@ -16,27 +16,235 @@ limitations under the License.
 package es
 
 import (
+	"encoding/json"
+	"fmt"
+	"hebe/langs/goreq"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
 	"github.com/spf13/cobra"
 )
 
 // countCmd represents the es command
 var countCmd = &cobra.Command{
 	Use:   "count",
-	Short: "Document count of the entire cluster",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
+	Short: "Document count of the entire cluster or of indices",
+	Long: `Document count of the entire cluster, of an index pattern, or of the documents
+matching a query. --by breaks the count down per index or per value of a field,
+and --compare counts the same documents on a second cluster, e.g. to verify a migration:
+
+  hebe es count -i logs-2026.10.* --by index -c prod --compare staging
+  hebe es count -i logs-* -q 'status:500' --by host.name
 
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+With --compare the command exits with 1 when the counts differ, and fails when a --by field
+has more values than --by-size, the counts beyond them not being comparable.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		cluster := cmd.Flag("cluster").Value.String()
-		handleCatCommand(cluster, "count")
+		index, err := cmd.Flags().GetString("index")
+		check(err)
+		by, err := cmd.Flags().GetString("by")
+		check(err)
+		bySize, err := cmd.Flags().GetInt("by-size")
+		check(err)
+		compare, err := cmd.Flags().GetString("compare")
+		check(err)
+		index = strings.TrimSpace(index)
+		if by == "" && compare == "" && queryFlags.query == "" && queryFlags.body == "" {
+			if index != "" {
+				handleCatCommand(cluster, "count/"+index)
+				return
+			}
+			handleCatCommand(cluster, "count")
+			return
+		}
+
+		body, err := queryBody()
+		check(err)
+		counts, err := countDocs(cluster, index, body, by, bySize)
+		check(err)
+		key := "index"
+		if by != "" && by != "index" {
+			key = by
+		}
+		if compare == "" {
+			t := &Table{Columns: []string{key, "count"}}
+			for _, c := range counts {
+				t.Rows = append(t.Rows, []interface{}{c.key, c.count})
+			}
+			check(t.Write(os.Stdout, outputFormat))
+			return
+		}
+		other, err := countDocs(compare, index, body, by, bySize)
+		check(err)
+		for _, c := range []struct {
+			cluster string
+			counts  []docCount
+		}{{cluster, counts}, {compare, other}} {
+			if n := len(c.counts); n > 0 && c.counts[n-1].key == otherKey {
+				check(fmt.Errorf("%s has more than %d values on %s, raise --by-size to compare all of them", by, bySize, c.cluster))
+			}
+		}
+		t, differences := compareCounts(key, cluster, counts, compare, other)
+		check(t.Write(os.Stdout, outputFormat))
+		if differences > 0 {
+			fmt.Fprintf(os.Stderr, "%d of %d counts differ\n", differences, len(t.Rows))
+			os.Exit(exitError)
+		}
 	},
 }
 
 func init() {
 	EsCmd.AddCommand(countCmd)
+	addCatFlags(countCmd)
+	addQueryFlags(countCmd)
+
+	countCmd.Flags().StringP("index", "i", "", "index pattern")
+	countCmd.Flags().String("by", "", "break the count down per index, or per value of a field")
+	countCmd.Flags().Int("by-size", 100, "maximum number of --by field values")
+	countCmd.Flags().String("compare", "", "cluster profile or hosts to compare the counts with")
+}
+
+type docCount struct {
+	key   string
+	count int64
+}
+
+// countDocs counts the documents of index matching the query of body, in total or,
+// with by, per index or per value of the by field.
+func countDocs(cluster string, index string, body map[string]interface{}, by string, bySize int) ([]docCount, error) {
+	client, err := newClient(cluster)
+	if err != nil {
+		return nil, err
+	}
+	prefix := ""
+	if index != "" {
+		prefix = index + "/"
+	}
+	request := map[string]interface{}{}
+	if query, ok := body["query"]; ok {
+		request["query"] = query
+	}
+	if by == "" {
+		data, err := client.Request(goreq.POST, prefix+"_count", request)
+		if err != nil {
+			return nil, err
+		}
+		var resp struct {
+			Count int64 `json:"count"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return nil, err
+		}
+		key := index
+		if key == "" {
+			key = "_all"
+		}
+		return []docCount{{key, resp.Count}}, nil
+	}
+
+	if by == "index" {
+		return countIndices(client, index, request)
+	}
+	request["size"] = 0
+	request["aggs"] = map[string]interface{}{
+		"by": map[string]interface{}{"terms": map[string]interface{}{"field": by, "size": bySize}},
+	}
+	data, err := client.Request(goreq.POST, prefix+"_search", request)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Aggregations struct {
+			By struct {
+				SumOtherDocCount int64 `json:"sum_other_doc_count"`
+				Buckets          []struct {
+					Key         interface{} `json:"key"`
+					KeyAsString string      `json:"key_as_string"`
+					DocCount    int64       `json:"doc_count"`
+				} `json:"buckets"`
+			} `json:"by"`
+		} `json:"aggregations"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	var counts []docCount
+	for _, b := range resp.Aggregations.By.Buckets {
+		key := b.KeyAsString
+		if key == "" {
+			key = cell(b.Key)
+		}
+		counts = append(counts, docCount{key, b.DocCount})
+	}
+	if other := resp.Aggregations.By.SumOtherDocCount; other > 0 {
+		counts = append(counts, docCount{otherKey, other})
+	}
+	return counts, nil
+}
+
+// otherKey is the count of the values of a --by field beyond --by-size.
+const otherKey = "(other)"
+
+// countIndices counts the documents of each open index matching pattern, one index at a time
+// so that none is left out.
+func countIndices(client *Client, pattern string, request map[string]interface{}) ([]docCount, error) {
+	api := "indices"
+	if pattern != "" {
+		api += "/" + url.PathEscape(pattern)
+	}
+	t, err := callCatRequest(client, api, "h=index,status", "s=index")
+	if err != nil {
+		return nil, err
+	}
+	var counts []docCount
+	for i := range t.Rows {
+		index := t.Value(i, "index")
+		if t.Value(i, "status") == "close" {
+			continue
+		}
+		data, err := client.Request(goreq.POST, url.PathEscape(index)+"/_count", request)
+		if err != nil {
+			return nil, err
+		}
+		var resp struct {
+			Count int64 `json:"count"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return nil, err
+		}
+		counts = append(counts, docCount{index, resp.Count})
+	}
+	return counts, nil
+}
 
-	countCmd.Flags().StringP("cluster", "c", "localhost:9200", "es cluster")
+// compareCounts lines up the counts of two clusters by key, returning how many of them differ.
+func compareCounts(key string, name string, counts []docCount, otherName string, other []docCount) (*Table, int) {
+	values := map[string][2]int64{}
+	for _, c := range counts {
+		v := values[c.key]
+		v[0] = c.count
+		values[c.key] = v
+	}
+	for _, c := range other {
+		v := values[c.key]
+		v[1] = c.count
+		values[c.key] = v
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	t := &Table{Columns: []string{key, name, otherName, "diff"}}
+	differences := 0
+	for _, k := range keys {
+		v := values[k]
+		if v[0] != v[1] {
+			differences++
+		}
+		t.Rows = append(t.Rows, []interface{}{k, v[0], v[1], v[1] - v[0]})
+	}
+	return t, differences
 }
@@ -0,0 +1,223 @@
+/*
+Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package es
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hebe/langs/goreq"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+// diffCmd represents the es command
+var diffCmd = &cobra.Command{
+	Use:   "diff [cluster:]<index> [[cluster:]<index>]",
+	Short: "Compare the mappings and settings of indices, templates or clusters",
+	Long: `Compare the mappings and settings of two indices, an index and a template, or the same
+index on two clusters, listing the fields and settings added, removed or changed from the
+first to the second. Each side is an index, prefixed with a cluster profile to read it from
+another cluster, or a template given with --template. The internal settings of indices
+such as index.uuid are ignored, and so are the keys matching --ignore. The command exits
+with 1 when there are differences, e.g.
+
+  hebe es diff logs-2026.10.15 logs-2026.10.16
+  hebe es diff staging:logs-2026.10.16 prod:logs-2026.10.16 --mappings
+  hebe es diff logs-2026.10.16 --template logs --ignore 'index.number_of_*'`,
+	Args: cobra.RangeArgs(0, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		templates, err := cmd.Flags().GetStringArray("template")
+		check(err)
+		mappings, err := cmd.Flags().GetBool("mappings")
+		check(err)
+		settings, err := cmd.Flags().GetBool("settings")
+		check(err)
+		ignore, err := cmd.Flags().GetStringArray("ignore")
+		check(err)
+		if len(args)+len(templates) != 2 {
+			check(errors.New("diff compares two indices or templates"))
+		}
+		if !mappings && !settings {
+			mappings, settings = true, true
+		}
+
+		var sides []*diffSide
+		for _, spec := range args {
+			side, err := loadDiffSide(spec, cluster, false)
+			check(err)
+			sides = append(sides, side)
+		}
+		for _, spec := range templates {
+			side, err := loadDiffSide(spec, cluster, true)
+			check(err)
+			sides = append(sides, side)
+		}
+		a, b := sides[0], sides[1]
+		if a.label == b.label {
+			a.label, b.label = "first", "second"
+		}
+
+		t := &Table{Columns: []string{"section", "key", a.label, b.label, "change"}}
+		if mappings {
+			t.Rows = append(t.Rows, diffValues("mappings", a.mappings, b.mappings, ignore)...)
+		}
+		if settings {
+			t.Rows = append(t.Rows, diffValues("settings", a.settings, b.settings, ignore)...)
+		}
+		if len(t.Rows) == 0 {
+			fmt.Fprintln(os.Stderr, "no differences")
+			return
+		}
+		if (outputFormat == "" || outputFormat == "table") && isTerminal(os.Stdout) {
+			for _, row := range t.Rows {
+				row[4] = changeColors[row[4].(string)] + row[4].(string) + reset
+			}
+		}
+		check(t.Write(os.Stdout, outputFormat))
+		os.Exit(exitError)
+	},
+}
+
+func init() {
+	EsCmd.AddCommand(diffCmd)
+	diffCmd.Flags().StringArrayP("template", "t", nil, "compare the composable or legacy template [cluster:]<name>, repeatable")
+	diffCmd.Flags().Bool("mappings", false, "only compare the mappings")
+	diffCmd.Flags().Bool("settings", false, "only compare the settings")
+	diffCmd.Flags().StringArray("ignore", nil, "ignore the fields and settings matching this glob pattern, repeatable")
+}
+
+// changeColors highlight the changes on a terminal.
+var changeColors = map[string]string{
+	"added":   "\x1b[32m",
+	"removed": "\x1b[31m",
+	"changed": "\x1b[33m",
+}
+
+// diffSide is one of the indices or templates compared, their mappings and settings rendered as strings.
+type diffSide struct {
+	label    string
+	mappings map[string]string
+	settings map[string]string
+}
+
+func loadDiffSide(spec string, cluster string, template bool) (*diffSide, error) {
+	cluster, name := splitIndex(spec, cluster)
+	client, err := newClient(cluster)
+	if err != nil {
+		return nil, err
+	}
+	var def indexDefinition
+	label := spec
+	if template {
+		label = "template " + spec
+		if def, err = templateDefinition(client, name); err != nil {
+			return nil, err
+		}
+	} else {
+		indices, err := getIndices(client, name, false)
+		if err != nil {
+			return nil, err
+		}
+		if len(indices) != 1 {
+			return nil, fmt.Errorf("%s matches %d indices, diff compares single indices", name, len(indices))
+		}
+		for _, d := range indices {
+			def = d
+		}
+	}
+	side := &diffSide{label: label, mappings: map[string]string{}, settings: map[string]string{}}
+	for _, f := range flattenMapping(def.mappings()) {
+		side.mappings[f.field] = f.definition()
+	}
+	for k, v := range creatableSettings(def.settings()) {
+		side.settings[k] = settingValue(v)
+	}
+	return side, nil
+}
+
+// templateDefinition returns the effective mappings and settings of a composable template,
+// including its component templates, or those of a legacy template.
+func templateDefinition(client *Client, name string) (indexDefinition, error) {
+	var def indexDefinition
+	data, err := client.Request(goreq.POST, "_index_template/_simulate/"+url.PathEscape(name), nil)
+	if err == nil {
+		var resp struct {
+			Template indexDefinition `json:"template"`
+		}
+		err = json.Unmarshal(data, &resp)
+		return resp.Template, err
+	}
+	// not a composable template, or a version without them
+	if e, ok := err.(*ResponseError); !ok || e.Status >= 500 {
+		return def, err
+	}
+	data, err = client.Request(goreq.GET, "_template/"+url.PathEscape(name), nil)
+	if err != nil {
+		return def, err
+	}
+	var templates map[string]indexDefinition
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return def, err
+	}
+	def, ok := templates[name]
+	if !ok {
+		return def, fmt.Errorf("template %s not found", name)
+	}
+	return def, nil
+}
+
+// diffValues lists the keys added, removed or changed from a to b, except the ignored ones.
+func diffValues(section string, a, b map[string]string, ignore []string) [][]interface{} {
+	keys := map[string]bool{}
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var rows [][]interface{}
+next:
+	for _, k := range sorted {
+		for _, pattern := range ignore {
+			if ok, _ := path.Match(pattern, k); ok {
+				continue next
+			}
+		}
+		va, inA := a[k]
+		vb, inB := b[k]
+		switch {
+		case !inA:
+			rows = append(rows, []interface{}{section, k, nil, vb, "added"})
+		case !inB:
+			rows = append(rows, []interface{}{section, k, va, nil, "removed"})
+		case va != vb:
+			rows = append(rows, []interface{}{section, k, va, vb, "changed"})
+		}
+	}
+	return rows
+}
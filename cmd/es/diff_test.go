@@ -0,0 +1,56 @@
+package es
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffValues(t *testing.T) {
+	a := map[string]string{
+		"index.number_of_shards":   "3",
+		"index.number_of_replicas": "1",
+		"index.uuid":               "abc",
+		"index.codec":              "best_compression",
+	}
+	b := map[string]string{
+		"index.number_of_shards":   "3",
+		"index.number_of_replicas": "2",
+		"index.uuid":               "def",
+		"index.refresh_interval":   "5s",
+	}
+	tests := []struct {
+		name   string
+		a, b   map[string]string
+		ignore []string
+		want   [][]interface{}
+	}{
+		{
+			name: "added, removed and changed, sorted",
+			a:    a,
+			b:    b,
+			want: [][]interface{}{
+				{"settings", "index.codec", "best_compression", nil, "removed"},
+				{"settings", "index.number_of_replicas", "1", "2", "changed"},
+				{"settings", "index.refresh_interval", nil, "5s", "added"},
+				{"settings", "index.uuid", "abc", "def", "changed"},
+			},
+		},
+		{
+			name:   "ignored",
+			a:      a,
+			b:      b,
+			ignore: []string{"index.uuid", "index.number_of_*"},
+			want: [][]interface{}{
+				{"settings", "index.codec", "best_compression", nil, "removed"},
+				{"settings", "index.refresh_interval", nil, "5s", "added"},
+			},
+		},
+		{name: "same", a: a, b: a},
+		{name: "empty", a: map[string]string{}, b: nil},
+	}
+	for _, test := range tests {
+		if got := diffValues("settings", test.a, test.b, test.ignore); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: diffValues = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,582 @@
+/*
+Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package es
+
+import (
+	"encoding/json"
+	"fmt"
+	"hebe/langs/goreq"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// doctorCmd represents the es command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Run a battery of health checks on a cluster",
+	Long: `Run a battery of health checks on a cluster and grade each of them OK, WARN or CRIT:
+cluster status, disk watermarks, heap pressure, thread pool rejections, shard sizes,
+shards per node, red and yellow indices, pending tasks, node versions and deprecations.
+A check which cannot be run is graded UNKNOWN, e.g.
+
+  hebe es doctor
+  hebe es doctor -o json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		var err error
+		d := &doctor{tables: map[string]*Table{}}
+		d.heapWarn, err = cmd.Flags().GetFloat64("heap-warn")
+		check(err)
+		d.heapCrit, err = cmd.Flags().GetFloat64("heap-crit")
+		check(err)
+		size, err := cmd.Flags().GetString("max-shard-size")
+		check(err)
+		d.maxShardSize, err = parseByteSize(size)
+		check(err)
+		size, err = cmd.Flags().GetString("min-shard-size")
+		check(err)
+		d.minShardSize, err = parseByteSize(size)
+		check(err)
+		d.pendingWarn, err = cmd.Flags().GetDuration("pending-warn")
+		check(err)
+		d.pendingCrit, err = cmd.Flags().GetDuration("pending-crit")
+		check(err)
+		d.client, err = newClient(cluster)
+		check(err)
+
+		t := &Table{Columns: []string{"check", "status", "value", "message"}}
+		for _, f := range d.run() {
+			t.Rows = append(t.Rows, []interface{}{f.check, f.status.String(), f.value, f.message})
+		}
+		check(t.Write(os.Stdout, outputFormat))
+	},
+}
+
+func init() {
+	EsCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().Float64("heap-warn", 75, "heap percent of a node graded WARN")
+	doctorCmd.Flags().Float64("heap-crit", 85, "heap percent of a node graded CRIT")
+	doctorCmd.Flags().String("max-shard-size", "50gb", "size above which a primary shard is oversized")
+	doctorCmd.Flags().String("min-shard-size", "1gb", "average primary size under which an index with several primaries has too many shards")
+	doctorCmd.Flags().Duration("pending-warn", 30*time.Second, "age of the oldest pending task graded WARN")
+	doctorCmd.Flags().Duration("pending-crit", 5*time.Minute, "age of the oldest pending task graded CRIT")
+}
+
+// checkStatus grades a check, its value being the Nagios exit code.
+type checkStatus int
+
+const (
+	statusOK checkStatus = iota
+	statusWarn
+	statusCrit
+	statusUnknown
+)
+
+func (s checkStatus) String() string {
+	return [...]string{"OK", "WARN", "CRIT", "UNKNOWN"}[s]
+}
+
+// worse returns the worst of two grades, UNKNOWN being better than WARN and CRIT.
+func worse(a, b checkStatus) checkStatus {
+	rank := map[checkStatus]int{statusOK: 0, statusUnknown: 1, statusWarn: 2, statusCrit: 3}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// finding is the result of a doctor check.
+type finding struct {
+	check   string
+	status  checkStatus
+	value   string
+	message string
+}
+
+type doctor struct {
+	client       *Client
+	tables       map[string]*Table
+	settings     map[string]string
+	heapWarn     float64
+	heapCrit     float64
+	maxShardSize int
+	minShardSize int
+	pendingWarn  time.Duration
+	pendingCrit  time.Duration
+}
+
+// run runs every check, grading UNKNOWN those failing on an error other than a connection or authentication one.
+func (d *doctor) run() []finding {
+	checks := []struct {
+		name string
+		run  func() (finding, error)
+	}{
+		{"cluster status", d.clusterStatus},
+		{"disk watermarks", d.diskWatermarks},
+		{"heap", d.heap},
+		{"thread pool rejections", d.rejections},
+		{"shard sizes", d.shardSizes},
+		{"shards per node", d.shardsPerNode},
+		{"index health", d.indexHealth},
+		{"pending tasks", d.pendingTasks},
+		{"node versions", d.nodeVersions},
+		{"deprecations", d.deprecations},
+	}
+	var findings []finding
+	for _, c := range checks {
+		f, err := c.run()
+		if err != nil {
+			switch e := err.(type) {
+			case *ConnectionError:
+				check(e)
+			case *ResponseError:
+				if e.ExitCode() == exitAuth {
+					check(e)
+				}
+			}
+			f = finding{status: statusUnknown, message: err.Error()}
+		}
+		f.check = c.name
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// cat calls a _cat API once per run.
+func (d *doctor) cat(api string, options ...string) (*Table, error) {
+	key := api + "?" + strings.Join(options, "&")
+	if t, ok := d.tables[key]; ok {
+		return t, nil
+	}
+	t, err := callCatRequest(d.client, api, options...)
+	if err != nil {
+		return nil, err
+	}
+	d.tables[key] = t
+	return t, nil
+}
+
+// setting returns the effective value of a cluster setting, or def when the cluster does not report it.
+func (d *doctor) setting(name string, def string) (string, error) {
+	if d.settings == nil {
+		data, err := d.client.Request(goreq.GET, "_cluster/settings?include_defaults=true&flat_settings=true", nil)
+		if err != nil {
+			return "", err
+		}
+		var resp struct {
+			Transient  map[string]interface{} `json:"transient"`
+			Persistent map[string]interface{} `json:"persistent"`
+			Defaults   map[string]interface{} `json:"defaults"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return "", err
+		}
+		d.settings = map[string]string{}
+		// transient settings take precedence over persistent ones, which take precedence over defaults
+		for _, settings := range []map[string]interface{}{resp.Defaults, resp.Persistent, resp.Transient} {
+			for k, v := range settings {
+				d.settings[k] = cell(v)
+			}
+		}
+	}
+	if v, ok := d.settings[name]; ok {
+		return v, nil
+	}
+	return def, nil
+}
+
+// number parses a numeric _cat cell, empty cells being 0.
+func number(s string) float64 {
+	n, _ := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	return n
+}
+
+func (d *doctor) clusterStatus() (finding, error) {
+	t, err := d.cat("health", "h=status,unassign,init,relo")
+	if err != nil || len(t.Rows) == 0 {
+		return finding{}, err
+	}
+	status := t.Value(0, "status")
+	f := finding{status: statusOK, value: status}
+	switch status {
+	case "yellow":
+		f.status = statusWarn
+	case "red":
+		f.status = statusCrit
+	}
+	f.message = fmt.Sprintf("%s unassigned, %s initializing and %s relocating shards", t.Value(0, "unassign"), t.Value(0, "init"), t.Value(0, "relo"))
+	return f, nil
+}
+
+// watermark is a disk watermark, either a used percentage or an amount of free space.
+type watermark struct {
+	percent float64
+	free    int
+}
+
+func parseWatermark(s string) (watermark, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasSuffix(s, "%") {
+		n, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		return watermark{percent: n}, err
+	}
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return watermark{percent: n * 100}, nil
+	}
+	free, err := parseByteSize(s)
+	if err != nil {
+		return watermark{}, fmt.Errorf("invalid disk watermark %q", s)
+	}
+	return watermark{free: free}, nil
+}
+
+// exceeded tells if a disk is above the watermark, avail being negative when unknown.
+func (w watermark) exceeded(percent float64, avail int) bool {
+	if w.free > 0 {
+		return avail >= 0 && avail < w.free
+	}
+	return percent >= w.percent
+}
+
+func (d *doctor) diskWatermarks() (finding, error) {
+	levels := []struct {
+		name    string
+		setting string
+		def     string
+		status  checkStatus
+	}{
+		{"flood stage", "cluster.routing.allocation.disk.watermark.flood_stage", "95%", statusCrit},
+		{"high", "cluster.routing.allocation.disk.watermark.high", "90%", statusCrit},
+		{"low", "cluster.routing.allocation.disk.watermark.low", "85%", statusWarn},
+	}
+	watermarks := make([]watermark, len(levels))
+	for i, l := range levels {
+		v, err := d.setting(l.setting, l.def)
+		if err != nil {
+			return finding{}, err
+		}
+		if watermarks[i], err = parseWatermark(v); err != nil {
+			return finding{}, err
+		}
+	}
+	t, err := d.cat("allocation", "h=node,shards,disk.percent,disk.avail", "bytes=b")
+	if err != nil {
+		return finding{}, err
+	}
+	f := finding{status: statusOK}
+	var over []string
+	max := 0.0
+	for i := range t.Rows {
+		if t.Value(i, "disk.percent") == "" {
+			// the UNASSIGNED row
+			continue
+		}
+		percent := number(t.Value(i, "disk.percent"))
+		if percent > max {
+			max = percent
+		}
+		avail := -1
+		if v := t.Value(i, "disk.avail"); v != "" {
+			avail = int(number(v))
+		}
+		for j, l := range levels {
+			if watermarks[j].exceeded(percent, avail) {
+				f.status = worse(f.status, l.status)
+				over = append(over, fmt.Sprintf("%s %g%% above %s", t.Value(i, "node"), percent, l.name))
+				break
+			}
+		}
+	}
+	f.value = fmt.Sprintf("%g%%", max)
+	f.message = "no node above the low watermark"
+	if len(over) > 0 {
+		f.message = abbreviate(over)
+	}
+	return f, nil
+}
+
+func (d *doctor) heap() (finding, error) {
+	t, err := d.cat("nodes", "h=name,heap.percent,version")
+	if err != nil {
+		return finding{}, err
+	}
+	f := finding{status: statusOK}
+	var over []string
+	max := 0.0
+	for i := range t.Rows {
+		percent := number(t.Value(i, "heap.percent"))
+		if percent > max {
+			max = percent
+		}
+		switch {
+		case percent >= d.heapCrit:
+			f.status = worse(f.status, statusCrit)
+		case percent >= d.heapWarn:
+			f.status = worse(f.status, statusWarn)
+		default:
+			continue
+		}
+		over = append(over, fmt.Sprintf("%s %g%%", t.Value(i, "name"), percent))
+	}
+	f.value = fmt.Sprintf("%g%%", max)
+	f.message = fmt.Sprintf("every node under %g%%", d.heapWarn)
+	if len(over) > 0 {
+		f.message = abbreviate(over)
+	}
+	return f, nil
+}
+
+func (d *doctor) rejections() (finding, error) {
+	t, err := d.cat("thread_pool", "h=node_name,name,queue,rejected")
+	if err != nil {
+		return finding{}, err
+	}
+	f := finding{status: statusOK, message: "no rejections"}
+	var pools []string
+	total := 0.0
+	for i := range t.Rows {
+		rejected := number(t.Value(i, "rejected"))
+		if rejected == 0 {
+			continue
+		}
+		total += rejected
+		pools = append(pools, fmt.Sprintf("%s/%s %g", t.Value(i, "node_name"), t.Value(i, "name"), rejected))
+	}
+	f.value = fmt.Sprintf("%g", total)
+	if len(pools) > 0 {
+		f.status = statusWarn
+		f.message = abbreviate(pools) + " since the nodes started"
+	}
+	return f, nil
+}
+
+func (d *doctor) shardSizes() (finding, error) {
+	t, err := d.cat("shards", "h=index,shard,prirep,state,store", "bytes=b")
+	if err != nil {
+		return finding{}, err
+	}
+	var oversized, tiny []string
+	primaries := map[string]int{}
+	sizes := map[string]int{}
+	for i := range t.Rows {
+		if t.Value(i, "prirep") != "p" {
+			continue
+		}
+		index := t.Value(i, "index")
+		size := int(number(t.Value(i, "store")))
+		primaries[index]++
+		sizes[index] += size
+		if size > d.maxShardSize {
+			oversized = append(oversized, fmt.Sprintf("%s[%s] %s", index, t.Value(i, "shard"), byteSize(int64(size))))
+		}
+	}
+	for index, n := range primaries {
+		if n > 1 && sizes[index]/n < d.minShardSize {
+			tiny = append(tiny, fmt.Sprintf("%s %d shards of %s", index, n, byteSize(int64(sizes[index]/n))))
+		}
+	}
+	sort.Strings(tiny)
+	f := finding{status: statusOK, value: fmt.Sprint(len(oversized) + len(tiny)), message: "every primary shard sized between " + byteSize(int64(d.minShardSize)) + " and " + byteSize(int64(d.maxShardSize))}
+	var messages []string
+	if len(oversized) > 0 {
+		messages = append(messages, "oversized "+abbreviate(oversized))
+	}
+	if len(tiny) > 0 {
+		messages = append(messages, "too many shards for their size "+abbreviate(tiny))
+	}
+	if len(messages) > 0 {
+		f.status = statusWarn
+		f.message = strings.Join(messages, "; ")
+	}
+	return f, nil
+}
+
+func (d *doctor) shardsPerNode() (finding, error) {
+	v, err := d.setting("cluster.max_shards_per_node", "1000")
+	if err != nil {
+		return finding{}, err
+	}
+	limit := number(v)
+	t, err := d.cat("allocation", "h=node,shards,disk.percent,disk.avail", "bytes=b")
+	if err != nil {
+		return finding{}, err
+	}
+	f := finding{status: statusOK, message: fmt.Sprintf("every node under 80%% of cluster.max_shards_per_node %s", v)}
+	var over []string
+	max := 0.0
+	for i := range t.Rows {
+		if t.Value(i, "disk.percent") == "" {
+			continue
+		}
+		shards := number(t.Value(i, "shards"))
+		if shards > max {
+			max = shards
+		}
+		switch {
+		case limit > 0 && shards >= limit:
+			f.status = worse(f.status, statusCrit)
+		case limit > 0 && shards >= 0.8*limit:
+			f.status = worse(f.status, statusWarn)
+		default:
+			continue
+		}
+		over = append(over, fmt.Sprintf("%s %g", t.Value(i, "node"), shards))
+	}
+	f.value = fmt.Sprintf("%g", max)
+	if len(over) > 0 {
+		f.message = abbreviate(over) + " of cluster.max_shards_per_node " + v
+	}
+	return f, nil
+}
+
+func (d *doctor) indexHealth() (finding, error) {
+	t, err := d.cat("indices", "h=health,status,index", "s=index")
+	if err != nil {
+		return finding{}, err
+	}
+	var red, yellow []string
+	for i := range t.Rows {
+		switch t.Value(i, "health") {
+		case "red":
+			red = append(red, t.Value(i, "index"))
+		case "yellow":
+			yellow = append(yellow, t.Value(i, "index"))
+		}
+	}
+	f := finding{status: statusOK, value: fmt.Sprintf("%d red, %d yellow", len(red), len(yellow)), message: fmt.Sprintf("%d indices green or closed", len(t.Rows))}
+	var messages []string
+	if len(red) > 0 {
+		f.status = statusCrit
+		messages = append(messages, "red "+abbreviate(red))
+	}
+	if len(yellow) > 0 {
+		f.status = worse(f.status, statusWarn)
+		messages = append(messages, "yellow "+abbreviate(yellow))
+	}
+	if len(messages) > 0 {
+		f.message = strings.Join(messages, "; ")
+	}
+	return f, nil
+}
+
+func (d *doctor) pendingTasks() (finding, error) {
+	t, err := d.cat("pending_tasks", "h=insertOrder,timeInQueue,priority,source", "time=ms")
+	if err != nil {
+		return finding{}, err
+	}
+	oldest := time.Duration(0)
+	source := ""
+	for i := range t.Rows {
+		if age := time.Duration(number(t.Value(i, "timeInQueue"))) * time.Millisecond; age > oldest {
+			oldest, source = age, t.Value(i, "source")
+		}
+	}
+	f := finding{status: statusOK, value: oldest.String(), message: fmt.Sprintf("%d pending tasks", len(t.Rows))}
+	switch {
+	case oldest >= d.pendingCrit:
+		f.status = statusCrit
+	case oldest >= d.pendingWarn:
+		f.status = statusWarn
+	}
+	if source != "" {
+		f.message += ", the oldest " + source
+	}
+	return f, nil
+}
+
+func (d *doctor) nodeVersions() (finding, error) {
+	t, err := d.cat("nodes", "h=name,heap.percent,version")
+	if err != nil {
+		return finding{}, err
+	}
+	nodes := map[string][]string{}
+	var versions []string
+	for i := range t.Rows {
+		v := t.Value(i, "version")
+		if _, ok := nodes[v]; !ok {
+			versions = append(versions, v)
+		}
+		nodes[v] = append(nodes[v], t.Value(i, "name"))
+	}
+	sort.Strings(versions)
+	f := finding{status: statusOK, value: strings.Join(versions, ", "), message: fmt.Sprintf("%d nodes on the same version", len(t.Rows))}
+	if len(versions) > 1 {
+		f.status = statusWarn
+		var groups []string
+		for _, v := range versions {
+			groups = append(groups, fmt.Sprintf("%s on %s", v, abbreviate(nodes[v])))
+		}
+		f.message = strings.Join(groups, "; ")
+	}
+	return f, nil
+}
+
+// deprecation is an issue reported by the deprecation info API.
+type deprecation struct {
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+func (d *doctor) deprecations() (finding, error) {
+	data, err := d.client.Request(goreq.GET, "_migration/deprecations", nil)
+	if err != nil {
+		return finding{}, err
+	}
+	var resp map[string]json.RawMessage
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return finding{}, err
+	}
+	var issues []deprecation
+	for _, raw := range resp {
+		// cluster and node issues are lists, index issues are lists by index name
+		var list []deprecation
+		if err := json.Unmarshal(raw, &list); err == nil {
+			issues = append(issues, list...)
+			continue
+		}
+		var byIndex map[string][]deprecation
+		if err := json.Unmarshal(raw, &byIndex); err == nil {
+			for _, list := range byIndex {
+				issues = append(issues, list...)
+			}
+		}
+	}
+	f := finding{status: statusOK, value: fmt.Sprint(len(issues)), message: "no deprecated settings"}
+	seen := map[string]bool{}
+	var messages []string
+	for _, issue := range issues {
+		switch issue.Level {
+		case "critical":
+			f.status = worse(f.status, statusCrit)
+		case "warning":
+			f.status = worse(f.status, statusWarn)
+		}
+		if !seen[issue.Message] {
+			seen[issue.Message] = true
+			messages = append(messages, issue.Message)
+		}
+	}
+	sort.Strings(messages)
+	if len(messages) > 0 {
+		f.message = abbreviate(messages)
+	}
+	return f, nil
+}
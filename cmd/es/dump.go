@@ -0,0 +1,382 @@
+/*
+Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package es
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hebe/langs/goreq"
+	"hebe/langs/parquet"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// dumpCmd represents the es command
+var dumpCmd = &cobra.Command{
+	Use:   "dump <index>",
+	Short: "Export the documents of an index to NDJSON, CSV or Parquet",
+	Long: `Export all documents of an index, or those matching a query, streaming them
+page by page within a point in time, or with scroll on clusters before 7.10, e.g.
+
+  hebe es dump logs-2026.10.* -f logs.ndjson.gz --slices 4
+  hebe es dump users -q 'country:FR' --fields _id,name,email -f users.csv
+  hebe es dump metrics --fields @timestamp,host.name,cpu -f metrics.parquet
+
+NDJSON lines hold the _index, _id and _source of each document, which is what
+load reads back. CSV and Parquet need --fields, where _index, _id and _score
+name the metadata of the documents. The format defaults to the extension of
+--file, and a .gz extension or --gzip compresses the output.
+
+--slices splits the export into parallel sliced searches; documents then come out
+in no particular order.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		format, err := cmd.Flags().GetString("format")
+		check(err)
+		fields, err := cmd.Flags().GetString("fields")
+		check(err)
+		file, err := cmd.Flags().GetString("file")
+		check(err)
+		compress, err := cmd.Flags().GetBool("gzip")
+		check(err)
+		maxDocs, err := cmd.Flags().GetInt("max-docs")
+		check(err)
+		slices, err := cmd.Flags().GetInt("slices")
+		check(err)
+		pageSize, err := cmd.Flags().GetInt("page-size")
+		check(err)
+		scroll, err := cmd.Flags().GetBool("scroll")
+		check(err)
+
+		name := strings.TrimSuffix(file, ".gz")
+		compress = compress || name != file
+		if format == "" {
+			switch ext := filepath.Ext(name); ext {
+			case ".csv", ".parquet":
+				format = ext[1:]
+			default:
+				format = "ndjson"
+			}
+		}
+		var columns []string
+		if fields != "" {
+			columns = strings.Split(fields, ",")
+		}
+		if format != "ndjson" && len(columns) == 0 {
+			check(fmt.Errorf("--fields is required for %s output", format))
+		}
+
+		client, err := newClient(cluster)
+		check(err)
+		body, err := queryBody()
+		check(err)
+		if source := sourceFields(columns); len(source) > 0 {
+			body["_source"] = source
+		}
+
+		var out io.Writer = os.Stdout
+		if file != "" && file != "-" {
+			f, err := os.Create(file)
+			check(err)
+			defer f.Close()
+			out = f
+		}
+		buffered := bufio.NewWriter(out)
+		out = buffered
+		var zipped *gzip.Writer
+		if compress {
+			zipped = gzip.NewWriter(buffered)
+			out = zipped
+		}
+		w, err := newDocWriter(out, format, columns)
+		check(err)
+
+		d := &dumper{client: client, index: args[0], body: body, pageSize: pageSize, max: maxDocs}
+		d.progress.total = d.count()
+		d.progress.start(2 * time.Second)
+		err = d.run(w, slices, scroll)
+		if err == nil {
+			err = w.close()
+		}
+		if err == nil && zipped != nil {
+			err = zipped.Close()
+		}
+		if err == nil {
+			err = buffered.Flush()
+		}
+		d.progress.stop()
+		check(err)
+	},
+}
+
+func init() {
+	EsCmd.AddCommand(dumpCmd)
+	addQueryFlags(dumpCmd)
+
+	dumpCmd.Flags().String("format", "", "output format: ndjson, csv or parquet, by default from the --file extension")
+	dumpCmd.Flags().String("fields", "", "comma separated fields to export, required for csv and parquet")
+	dumpCmd.Flags().StringP("file", "f", "", "file to write, stdout by default")
+	dumpCmd.Flags().Bool("gzip", false, "gzip the output")
+	dumpCmd.Flags().Int("max-docs", 0, "stop after this many documents")
+	dumpCmd.Flags().Int("slices", 1, "number of parallel sliced searches")
+	dumpCmd.Flags().Int("page-size", 1000, "number of documents fetched per request")
+	dumpCmd.Flags().Bool("scroll", false, "use scroll instead of a point in time")
+}
+
+// metaFields are the fields of a hit outside of its source.
+var metaFields = map[string]bool{"_index": true, "_id": true, "_score": true}
+
+// sourceFields returns the fields to fetch from the source of the documents.
+func sourceFields(fields []string) []string {
+	var source []string
+	for _, f := range fields {
+		if !metaFields[f] {
+			source = append(source, f)
+		}
+	}
+	return source
+}
+
+// hitValue returns a metadata field or a source field of a hit.
+func hitValue(h *hit, field string) interface{} {
+	switch field {
+	case "_index":
+		return h.Index
+	case "_id":
+		return h.ID
+	case "_score":
+		return h.Score
+	}
+	return fieldValue(h.Source, field)
+}
+
+// docWriter writes exported documents in one of the dump formats.
+type docWriter interface {
+	write(h *hit) error
+	close() error
+}
+
+func newDocWriter(w io.Writer, format string, fields []string) (docWriter, error) {
+	switch format {
+	case "ndjson":
+		return &ndjsonWriter{json.NewEncoder(w)}, nil
+	case "csv":
+		c := csv.NewWriter(w)
+		return &csvWriter{c, fields}, c.Write(fields)
+	case "parquet":
+		return &parquetWriter{parquet.NewWriter(w, fields), fields}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q, expected ndjson, csv or parquet", format)
+}
+
+type ndjsonWriter struct {
+	e *json.Encoder
+}
+
+func (w *ndjsonWriter) write(h *hit) error {
+	return w.e.Encode(struct {
+		Index  string                 `json:"_index"`
+		ID     string                 `json:"_id"`
+		Source map[string]interface{} `json:"_source"`
+	}{h.Index, h.ID, h.Source})
+}
+
+func (w *ndjsonWriter) close() error {
+	return nil
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	fields []string
+}
+
+func (w *csvWriter) write(h *hit) error {
+	record := make([]string, len(w.fields))
+	for i, f := range w.fields {
+		record[i] = cell(hitValue(h, f))
+	}
+	return w.w.Write(record)
+}
+
+func (w *csvWriter) close() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+type parquetWriter struct {
+	w      *parquet.Writer
+	fields []string
+}
+
+func (w *parquetWriter) write(h *hit) error {
+	row := make([]*string, len(w.fields))
+	for i, f := range w.fields {
+		if v := hitValue(h, f); v != nil {
+			s := cell(v)
+			row[i] = &s
+		}
+	}
+	return w.w.Write(row)
+}
+
+func (w *parquetWriter) close() error {
+	return w.w.Close()
+}
+
+// errDumped stops the searches once --max-docs documents were written.
+var errDumped = errors.New("enough documents dumped")
+
+// dumper streams the documents of an index matching body to a docWriter.
+type dumper struct {
+	client   *Client
+	index    string
+	body     map[string]interface{}
+	pageSize int
+	max      int
+
+	progress progress
+}
+
+// count returns the number of documents to dump, or -1 when unknown.
+func (d *dumper) count() int64 {
+	request := map[string]interface{}{}
+	if query, ok := d.body["query"]; ok {
+		request["query"] = query
+	}
+	data, err := d.client.Request(goreq.POST, d.index+"/_count", request)
+	if err != nil {
+		return -1
+	}
+	var resp struct {
+		Count int64 `json:"count"`
+	}
+	if json.Unmarshal(data, &resp) != nil {
+		return -1
+	}
+	if d.max > 0 && int64(d.max) < resp.Count {
+		return int64(d.max)
+	}
+	return resp.Count
+}
+
+// run searches the documents with slices parallel searches, writing their hits from
+// a single goroutine.
+func (d *dumper) run(w docWriter, slices int, scroll bool) error {
+	if slices < 1 {
+		slices = 1
+	}
+	// search_after needs a point in time to go through all shards consistently
+	s, err := openSearcher(d.client, d.index, !scroll)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	s.scroll = s.scroll || scroll
+
+	pages := make(chan []hit, slices)
+	done := make(chan struct{})
+	errs := make(chan error, slices)
+	var wg sync.WaitGroup
+	for i := 0; i < slices; i++ {
+		body := map[string]interface{}{}
+		for k, v := range d.body {
+			body[k] = v
+		}
+		if _, ok := body["sort"]; !ok {
+			// index order, the cheapest to page through without scoring
+			body["sort"] = []interface{}{"_shard_doc"}
+			if s.scroll {
+				body["sort"] = []interface{}{"_doc"}
+			}
+		}
+		if slices > 1 {
+			body["slice"] = map[string]interface{}{"id": i, "max": slices}
+		}
+		fn := func(resp *searchResponse) error {
+			select {
+			case pages <- resp.Hits.Hits:
+				return nil
+			case <-done:
+				return errDumped
+			}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.pages(body, d.pageSize, 0, fn); err != nil && err != errDumped {
+				errs <- err
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(pages)
+	}()
+
+	fail := func(e error) {
+		if err == nil {
+			err = e
+			close(done)
+		}
+	}
+	for pages != nil {
+		select {
+		case hits, ok := <-pages:
+			if !ok {
+				pages = nil
+			} else if err == nil {
+				// after a failure, the remaining pages are dropped until the searches stop
+				if e := d.write(w, hits); e != nil {
+					fail(e)
+				}
+			}
+		case e := <-errs:
+			fail(e)
+		}
+	}
+	if err == nil && len(errs) > 0 {
+		err = <-errs
+	}
+	if err == errDumped {
+		return nil
+	}
+	return err
+}
+
+// write writes hits up to --max-docs documents, returning errDumped once they are written.
+func (d *dumper) write(w docWriter, hits []hit) error {
+	for i := range hits {
+		if err := w.write(&hits[i]); err != nil {
+			return err
+		}
+		d.progress.add(1)
+		if d.max > 0 && d.progress.value() >= int64(d.max) {
+			return errDumped
+		}
+	}
+	return nil
+}
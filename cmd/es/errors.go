@@ -0,0 +1,117 @@
+package es
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Exit codes of es commands.
+const (
+	exitError      = 1 // any other failure
+	exitConnection = 3 // the cluster could not be reached
+	exitAuth       = 4 // 401 or 403
+	exitNotFound   = 5 // 404
+	exitRequest    = 6 // other 4xx
+	exitServer     = 7 // 5xx
+)
+
+// Cause is one entry of an error response's root_cause.
+type Cause struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+	Index  string `json:"index"`
+}
+
+// ResponseError is an error status returned by Elasticsearch.
+type ResponseError struct {
+	Status    int
+	Type      string
+	Reason    string
+	RootCause []Cause
+	Body      []byte
+}
+
+func newResponseError(status int, body []byte) *ResponseError {
+	e := &ResponseError{Status: status, Body: body}
+	var resp struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(body, &resp); err == nil && len(resp.Error) > 0 {
+		var detail struct {
+			Cause
+			RootCause []Cause `json:"root_cause"`
+		}
+		if err := json.Unmarshal(resp.Error, &detail); err == nil {
+			e.Type, e.Reason, e.RootCause = detail.Type, detail.Reason, detail.RootCause
+		} else {
+			// versions before 5.0 report the error as a plain string
+			json.Unmarshal(resp.Error, &e.Reason)
+		}
+	}
+	if e.Reason == "" {
+		e.Reason = http.StatusText(status)
+		if line := strings.TrimSpace(strings.SplitN(string(body), "\n", 2)[0]); line != "" && len(line) < 200 {
+			e.Reason = line
+		}
+	}
+	return e
+}
+
+func (e *ResponseError) Error() string {
+	msg := e.Reason
+	if e.Type != "" {
+		msg = e.Type + ": " + e.Reason
+	}
+	for _, c := range e.RootCause {
+		if c.Type != e.Type || c.Reason != e.Reason {
+			msg += fmt.Sprintf(", caused by %s: %s", c.Type, c.Reason)
+		}
+	}
+	return fmt.Sprintf("%s (%d)", msg, e.Status)
+}
+
+func (e *ResponseError) ExitCode() int {
+	switch {
+	case e.Status == http.StatusUnauthorized || e.Status == http.StatusForbidden:
+		return exitAuth
+	case e.Status == http.StatusNotFound:
+		return exitNotFound
+	case e.Status >= 500:
+		return exitServer
+	default:
+		return exitRequest
+	}
+}
+
+// ConnectionError is a failure to get any response from the cluster.
+type ConnectionError struct {
+	URL string
+	Err error
+}
+
+func (e *ConnectionError) Error() string {
+	return fmt.Sprintf("cannot reach %s: %v", e.URL, e.Err)
+}
+
+func (e *ConnectionError) ExitCode() int {
+	return exitConnection
+}
+
+// check prints a concise message for err and exits with its exit code, if err is not nil.
+// With --verbose the full error response is printed as well.
+func check(err error) {
+	if err == nil {
+		return
+	}
+	fmt.Fprintln(os.Stderr, "error:", err)
+	if e, ok := err.(*ResponseError); ok && verbose && len(e.Body) > 0 {
+		fmt.Fprintln(os.Stderr, string(e.Body))
+	}
+	if e, ok := err.(interface{ ExitCode() int }); ok {
+		os.Exit(e.ExitCode())
+	}
+	os.Exit(exitError)
+}
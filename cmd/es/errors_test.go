@@ -0,0 +1,109 @@
+package es
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewResponseError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		typ    string
+		reason string
+		err    string
+		exit   int
+	}{
+		{
+			name:   "typed",
+			status: 404,
+			body:   `{"error":{"root_cause":[{"type":"index_not_found_exception","reason":"no such index [logs]","index":"logs"}],"type":"index_not_found_exception","reason":"no such index [logs]","index":"logs"},"status":404}`,
+			typ:    "index_not_found_exception",
+			reason: "no such index [logs]",
+			err:    "index_not_found_exception: no such index [logs] (404)",
+			exit:   exitNotFound,
+		},
+		{
+			name:   "root cause",
+			status: 400,
+			body:   `{"error":{"root_cause":[{"type":"parsing_exception","reason":"unknown query [mach]"}],"type":"search_phase_execution_exception","reason":"all shards failed"},"status":400}`,
+			typ:    "search_phase_execution_exception",
+			reason: "all shards failed",
+			err:    "search_phase_execution_exception: all shards failed, caused by parsing_exception: unknown query [mach] (400)",
+			exit:   exitRequest,
+		},
+		{
+			name:   "string error before 5.0",
+			status: 400,
+			body:   `{"error":"IndexMissingException[[logs] missing]","status":404}`,
+			reason: "IndexMissingException[[logs] missing]",
+			err:    "IndexMissingException[[logs] missing] (400)",
+			exit:   exitRequest,
+		},
+		{
+			name:   "security",
+			status: 403,
+			body:   `{"error":{"root_cause":[{"type":"security_exception","reason":"action [indices:data/read/search] is unauthorized"}],"type":"security_exception","reason":"action [indices:data/read/search] is unauthorized"},"status":403}`,
+			typ:    "security_exception",
+			reason: "action [indices:data/read/search] is unauthorized",
+			err:    "security_exception: action [indices:data/read/search] is unauthorized (403)",
+			exit:   exitAuth,
+		},
+		{
+			name:   "plain text",
+			status: 401,
+			body:   "Unauthorized\nmore",
+			reason: "Unauthorized",
+			err:    "Unauthorized (401)",
+			exit:   exitAuth,
+		},
+		{
+			name:   "empty body",
+			status: 502,
+			reason: "Bad Gateway",
+			err:    "Bad Gateway (502)",
+			exit:   exitServer,
+		},
+		{
+			name:   "server",
+			status: 503,
+			body:   `{"error":{"type":"cluster_block_exception","reason":"blocked by: [SERVICE_UNAVAILABLE/1/state not recovered / initialized];"},"status":503}`,
+			typ:    "cluster_block_exception",
+			reason: "blocked by: [SERVICE_UNAVAILABLE/1/state not recovered / initialized];",
+			err:    "cluster_block_exception: blocked by: [SERVICE_UNAVAILABLE/1/state not recovered / initialized]; (503)",
+			exit:   exitServer,
+		},
+		{
+			name:   "conflict",
+			status: 409,
+			body:   `{"error":{"type":"version_conflict_engine_exception","reason":"[1]: version conflict"},"status":409}`,
+			typ:    "version_conflict_engine_exception",
+			reason: "[1]: version conflict",
+			err:    "version_conflict_engine_exception: [1]: version conflict (409)",
+			exit:   exitRequest,
+		},
+	}
+	for _, test := range tests {
+		e := newResponseError(test.status, []byte(test.body))
+		if e.Status != test.status || e.Type != test.typ || e.Reason != test.reason {
+			t.Errorf("%s: status %d, type %q, reason %q, want %d, %q, %q", test.name, e.Status, e.Type, e.Reason, test.status, test.typ, test.reason)
+		}
+		if e.Error() != test.err {
+			t.Errorf("%s: Error() = %q, want %q", test.name, e.Error(), test.err)
+		}
+		if e.ExitCode() != test.exit {
+			t.Errorf("%s: ExitCode() = %d, want %d", test.name, e.ExitCode(), test.exit)
+		}
+	}
+}
+
+func TestConnectionError(t *testing.T) {
+	e := &ConnectionError{URL: "http://localhost:9200", Err: errors.New("connection refused")}
+	if e.Error() != "cannot reach http://localhost:9200: connection refused" {
+		t.Errorf("Error() = %q", e.Error())
+	}
+	if e.ExitCode() != exitConnection {
+		t.Errorf("ExitCode() = %d, want %d", e.ExitCode(), exitConnection)
+	}
+}
@@ -18,6 +18,7 @@ package es
 import (
 	"github.com/spf13/cobra"
 	"hebe/cmd"
+	"strings"
 )
 
 // esCmd represents the es command
@@ -32,6 +33,38 @@ This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
 }
 
+// flagCluster holds connection settings given on the command line, which take
+// precedence over the cluster profile.
+var flagCluster Cluster
+
+// outputFormat is how command results are rendered, one of outputFormats.
+var outputFormat string
+
+// verbose prints full error responses.
+var verbose bool
+
+// guardFlags relax the guardrails of the cluster for one command.
+var guardFlags struct {
+	allowWildcard  bool
+	confirmCluster string
+}
+
 func init() {
 	cmd.AddCommand(EsCmd)
+
+	EsCmd.PersistentFlags().StringP("cluster", "c", "localhost:9200", "cluster profile from config, or comma separated es hosts")
+	EsCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "table", "output format: "+strings.Join(outputFormats, ", "))
+	EsCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "print the full response of failed requests")
+	EsCmd.PersistentFlags().StringVar(&flagCluster.Scheme, "scheme", "", "http or https, overrides the profile scheme")
+	EsCmd.PersistentFlags().StringVarP(&flagCluster.Username, "user", "u", "", "basic auth username")
+	EsCmd.PersistentFlags().StringVarP(&flagCluster.Password, "password", "p", "", "basic auth password")
+	EsCmd.PersistentFlags().StringVar(&flagCluster.APIKey, "api-key", "", "API key, encoded or as id:api_key")
+	EsCmd.PersistentFlags().StringVar(&flagCluster.Token, "token", "", "bearer token")
+	EsCmd.PersistentFlags().StringVar(&flagCluster.CACert, "ca-cert", "", "PEM file of CA certificates to trust")
+	EsCmd.PersistentFlags().StringVar(&flagCluster.ClientCert, "cert", "", "PEM client certificate")
+	EsCmd.PersistentFlags().StringVar(&flagCluster.ClientKey, "key", "", "PEM client certificate key")
+	EsCmd.PersistentFlags().BoolVar(&flagCluster.Sniff, "sniff", false, "discover the HTTP addresses of all nodes from the given hosts")
+	EsCmd.PersistentFlags().BoolVarP(&flagCluster.Insecure, "insecure", "k", false, "skip TLS certificate verification")
+	EsCmd.PersistentFlags().BoolVar(&guardFlags.allowWildcard, "allow-wildcard", false, "allow deleting indices by wildcard patterns")
+	EsCmd.PersistentFlags().StringVar(&guardFlags.confirmCluster, "confirm-cluster", "", "name of the production cluster, confirming destructive requests without a prompt")
 }
@@ -0,0 +1,202 @@
+/*
+Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package es
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// exporterCmd represents the es command
+var exporterCmd = &cobra.Command{
+	Use:   "exporter",
+	Short: "Serve Prometheus metrics of the configured clusters",
+	Long: `Scrape clusters every interval and serve their health, nodes, disks, indices, thread pools
+and shard states as Prometheus metrics on /metrics, labelled with the cluster profile name
+and its labels. Every profile of the config is scraped unless --clusters is given, e.g.
+
+  hebe es exporter --listen :9114 --interval 30s
+  hebe es exporter --clusters prod-logs,staging --indices 'logs-*'`,
+	Run: func(cmd *cobra.Command, args []string) {
+		listen, err := cmd.Flags().GetString("listen")
+		check(err)
+		interval, err := cmd.Flags().GetDuration("interval")
+		check(err)
+		if interval <= 0 {
+			check(fmt.Errorf("--interval must be positive, got %s", interval))
+		}
+		names, err := cmd.Flags().GetStringSlice("clusters")
+		check(err)
+		indices, err := cmd.Flags().GetString("indices")
+		check(err)
+		if len(names) == 0 {
+			profiles, err := loadProfiles()
+			check(err)
+			names = profileNames(profiles)
+		}
+		if len(names) == 0 {
+			names = []string{cmd.Flag("cluster").Value.String()}
+		}
+		e := &exporter{names: names, clients: make([]*Client, len(names)), indices: indices}
+		e.scrape()
+		go func() {
+			for {
+				time.Sleep(interval)
+				e.scrape()
+			}
+		}()
+		http.HandleFunc("/metrics", e.serve)
+		fmt.Fprintf(os.Stderr, "serving the metrics of %d clusters on %s/metrics\n", len(e.names), listen)
+		check(http.ListenAndServe(listen, nil))
+	},
+}
+
+func init() {
+	EsCmd.AddCommand(exporterCmd)
+	exporterCmd.Flags().String("listen", ":9114", "address to serve /metrics on")
+	exporterCmd.Flags().Duration("interval", 30*time.Second, "time between two scrapes of the clusters")
+	exporterCmd.Flags().StringSlice("clusters", nil, "comma separated cluster profiles to scrape, all by default")
+	exporterCmd.Flags().String("indices", "*", "index pattern of the per index metrics, empty for none")
+}
+
+// exporterQueries are the _cat APIs scraped for metrics, see metrics.addTable.
+var exporterQueries = []struct {
+	api     string
+	options []string
+}{
+	{"health", []string{"h=cluster,status,node.total,node.data,shards,pri,relo,init,unassign,pending_tasks,active_shards_percent"}},
+	{"nodes", []string{"h=name,node.role,heap.percent,ram.percent,cpu,load_1m,load_5m,load_15m"}},
+	{"allocation", []string{"h=node,shards,disk.indices,disk.used,disk.avail,disk.total,disk.percent", "bytes=b"}},
+	{"thread_pool", []string{"h=node_name,name,active,queue,rejected,completed"}},
+}
+
+// exporter keeps the metrics of the last scrape of its clusters.
+type exporter struct {
+	names   []string
+	clients []*Client
+	indices string
+
+	mu   sync.Mutex
+	body []byte
+}
+
+// scrape collects the metrics of every cluster concurrently.
+func (e *exporter) scrape() {
+	results := make([]*metrics, len(e.names))
+	var wg sync.WaitGroup
+	for i := range e.names {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = e.collect(i)
+		}(i)
+	}
+	wg.Wait()
+
+	all := newMetrics()
+	for _, m := range results {
+		all.merge(m)
+	}
+	var b bytes.Buffer
+	all.write(&b)
+	e.mu.Lock()
+	e.body = b.Bytes()
+	e.mu.Unlock()
+}
+
+// client returns the client of cluster i, created by the first scrape it succeeds in, so that
+// a cluster unreachable at startup, e.g. when sniffing its nodes, is reported down meanwhile.
+func (e *exporter) client(i int) (*Client, error) {
+	if e.clients[i] == nil {
+		client, err := newClient(e.names[i])
+		if err != nil {
+			return nil, err
+		}
+		e.clients[i] = client
+	}
+	return e.clients[i], nil
+}
+
+// collect scrapes cluster i, reporting it down on the first error.
+func (e *exporter) collect(i int) *metrics {
+	m := newMetrics()
+	started := time.Now()
+	labels := []label{{"cluster", e.names[i]}}
+	if cluster, err := loadCluster(e.names[i]); err == nil {
+		labels = cluster.metricLabels()
+	}
+	err := func() error {
+		client, err := e.client(i)
+		if err != nil {
+			return err
+		}
+		for _, q := range exporterQueries {
+			t, err := callCatRequest(client, q.api, q.options...)
+			if err != nil {
+				return err
+			}
+			m.addTable("hebe_es_"+q.api, q.api, labels, t)
+		}
+		if e.indices != "" {
+			t, err := callCatRequest(client, "indices/"+e.indices, "h=index,health,status,pri,rep,docs.count,docs.deleted,store.size,pri.store.size", "bytes=b")
+			if err != nil {
+				return err
+			}
+			m.addTable("hebe_es_indices", "indices", labels, t)
+		}
+		t, err := callCatRequest(client, "shards", "h=state")
+		if err != nil {
+			return err
+		}
+		states := map[string]int{}
+		for i := range t.Rows {
+			states[t.Value(i, "state")]++
+		}
+		names := make([]string, 0, len(states))
+		for state := range states {
+			names = append(names, state)
+		}
+		sort.Strings(names)
+		for _, state := range names {
+			m.add("hebe_es_shards", "shards of _cat/shards by state", append(labels, label{"state", state}), float64(states[state]))
+		}
+		return nil
+	}()
+	up := 1.0
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s scrape %s: %v\n", time.Now().Format(time.RFC3339), e.names[i], err)
+		// no partial metrics for a cluster down
+		m, up = newMetrics(), 0
+	}
+	m.add("hebe_es_up", "whether the last scrape of the cluster succeeded", labels, up)
+	m.add("hebe_es_scrape_duration_seconds", "duration of the last scrape of the cluster", labels, time.Since(started).Seconds())
+	return m
+}
+
+func (e *exporter) serve(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	body := e.body
+	e.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(body)
+}
@@ -0,0 +1,137 @@
+package es
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// operators for --where, longest first so that `!=` is not read as `!` and `=`
+var operators = []string{"!=", ">=", "<=", "=~", "!~", "=", ">", "<"}
+
+// condition is one parsed --where expression such as `health!=green` or `docs.count>1000`.
+type condition struct {
+	column string
+	op     string
+	value  string
+	re     *regexp.Regexp
+}
+
+func parseCondition(expr string) (*condition, error) {
+	for _, op := range operators {
+		i := strings.Index(expr, op)
+		if i <= 0 {
+			continue
+		}
+		c := &condition{
+			column: strings.TrimSpace(expr[:i]),
+			op:     op,
+			value:  strings.TrimSpace(expr[i+len(op):]),
+		}
+		if op == "=~" || op == "!~" {
+			re, err := regexp.Compile(c.value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --where %q: %v", expr, err)
+			}
+			c.re = re
+		}
+		return c, nil
+	}
+	return nil, fmt.Errorf("invalid --where %q, expected <column><op><value> with op one of %s", expr, strings.Join(operators, " "))
+}
+
+func (c *condition) match(s string) bool {
+	switch c.op {
+	case "=~":
+		return c.re.MatchString(s)
+	case "!~":
+		return !c.re.MatchString(s)
+	case "=", "!=":
+		equal := s == c.value
+		if strings.ContainsAny(c.value, "*?[") {
+			equal, _ = path.Match(c.value, s)
+		}
+		return equal == (c.op == "=")
+	}
+	cmp := strings.Compare(s, c.value)
+	a, errA := strconv.ParseFloat(s, 64)
+	b, errB := strconv.ParseFloat(c.value, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case a < b:
+			cmp = -1
+		case a > b:
+			cmp = 1
+		default:
+			cmp = 0
+		}
+	}
+	switch c.op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	default:
+		return cmp <= 0
+	}
+}
+
+// Filter keeps the rows matching all of the --where expressions.
+func (t *Table) Filter(exprs []string) error {
+	if len(exprs) == 0 {
+		return nil
+	}
+	index := map[string]int{}
+	for i, column := range t.Columns {
+		index[column] = i
+	}
+	var conditions []*condition
+	var columns []int
+	for _, expr := range exprs {
+		c, err := parseCondition(expr)
+		if err != nil {
+			return err
+		}
+		i, ok := index[c.column]
+		if !ok && len(t.Rows) > 0 {
+			return fmt.Errorf("unknown column %q in --where, available: %s", c.column, strings.Join(t.Columns, ","))
+		}
+		conditions = append(conditions, c)
+		columns = append(columns, i)
+	}
+	rows := t.Rows[:0]
+	for _, row := range t.Rows {
+		matched := true
+		for i, c := range conditions {
+			if !c.match(cell(row[columns[i]])) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			rows = append(rows, row)
+		}
+	}
+	t.Rows = rows
+	return nil
+}
+
+// Match reports whether the table has rows and all of them match the expressions.
+func (t *Table) Match(exprs []string) (bool, error) {
+	matched := &Table{Columns: t.Columns, Rows: append([][]interface{}(nil), t.Rows...)}
+	if err := matched.Filter(exprs); err != nil {
+		return false, err
+	}
+	return len(t.Rows) > 0 && len(matched.Rows) == len(t.Rows), nil
+}
+
+// Limit keeps at most n rows, n <= 0 keeps all.
+func (t *Table) Limit(n int) {
+	if n > 0 && len(t.Rows) > n {
+		t.Rows = t.Rows[:n]
+	}
+}
@@ -0,0 +1,130 @@
+package es
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		expr   string
+		column string
+		op     string
+		value  string
+		err    bool
+	}{
+		{expr: "health!=green", column: "health", op: "!=", value: "green"},
+		{expr: "docs.count>=1000", column: "docs.count", op: ">=", value: "1000"},
+		{expr: "disk.percent <= 80", column: "disk.percent", op: "<=", value: "80"},
+		{expr: "index=~^logs-", column: "index", op: "=~", value: "^logs-"},
+		{expr: "index!~^\\.", column: "index", op: "!~", value: "^\\."},
+		{expr: "status=open", column: "status", op: "=", value: "open"},
+		{expr: "pri>1", column: "pri", op: ">", value: "1"},
+		{expr: "rep<1", column: "rep", op: "<", value: "1"},
+		{expr: "index=", column: "index", op: "=", value: ""},
+		{expr: "health", err: true},
+		{expr: "=green", err: true},
+		{expr: "index=~(", err: true},
+	}
+	for _, test := range tests {
+		c, err := parseCondition(test.expr)
+		if test.err {
+			if err == nil {
+				t.Errorf("parseCondition(%q) = %+v, want an error", test.expr, c)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseCondition(%q): %v", test.expr, err)
+			continue
+		}
+		if c.column != test.column || c.op != test.op || c.value != test.value {
+			t.Errorf("parseCondition(%q) = %s %s %s, want %s %s %s", test.expr, c.column, c.op, c.value, test.column, test.op, test.value)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	table := func() *Table {
+		return &Table{
+			Columns: []string{"index", "health", "docs.count", "store.size"},
+			Rows: [][]interface{}{
+				{"logs-1", "green", "900", "10gb"},
+				{"logs-2", "yellow", "1000", "9gb"},
+				{"metrics", "red", "20000", nil},
+				{".security", "green", "10", "1mb"},
+			},
+		}
+	}
+	tests := []struct {
+		name  string
+		where []string
+		want  []string
+		err   bool
+	}{
+		{name: "none", want: []string{"logs-1", "logs-2", "metrics", ".security"}},
+		{name: "equal", where: []string{"health=green"}, want: []string{"logs-1", ".security"}},
+		{name: "not equal", where: []string{"health!=green"}, want: []string{"logs-2", "metrics"}},
+		{name: "glob", where: []string{"index=logs-*"}, want: []string{"logs-1", "logs-2"}},
+		{name: "not glob", where: []string{"index!=logs-*"}, want: []string{"metrics", ".security"}},
+		{name: "numbers", where: []string{"docs.count>=1000"}, want: []string{"logs-2", "metrics"}},
+		{name: "numbers not strings", where: []string{"docs.count<1000"}, want: []string{"logs-1", ".security"}},
+		{name: "strings", where: []string{"store.size>1"}, want: []string{"logs-1", "logs-2", ".security"}},
+		{name: "regexp", where: []string{"index=~^logs-[12]$"}, want: []string{"logs-1", "logs-2"}},
+		{name: "not regexp", where: []string{"index!~^\\."}, want: []string{"logs-1", "logs-2", "metrics"}},
+		{name: "empty cells", where: []string{"store.size="}, want: []string{"metrics"}},
+		{name: "all of", where: []string{"health=green", "docs.count>100"}, want: []string{"logs-1"}},
+		{name: "unknown column", where: []string{"size>1"}, err: true},
+		{name: "invalid", where: []string{"health"}, err: true},
+	}
+	for _, test := range tests {
+		tb := table()
+		err := tb.Filter(test.where)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: Filter(%q) succeeded, want an error", test.name, test.where)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Filter(%q): %v", test.name, test.where, err)
+			continue
+		}
+		var got []string
+		for i := range tb.Rows {
+			got = append(got, tb.Value(i, "index"))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: Filter(%q) kept %q, want %q", test.name, test.where, got, test.want)
+		}
+	}
+}
+
+func TestFilterNoRows(t *testing.T) {
+	tb := &Table{Columns: []string{"index"}}
+	if err := tb.Filter([]string{"size>1"}); err != nil {
+		t.Errorf("Filter on no rows: %v", err)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tb := &Table{Columns: []string{"state"}, Rows: [][]interface{}{{"DONE"}, {"STARTED"}}}
+	tests := []struct {
+		table *Table
+		until []string
+		want  bool
+	}{
+		{tb, []string{"state=DONE"}, false},
+		{tb, []string{"state=~DONE|STARTED"}, true},
+		{&Table{Columns: []string{"state"}}, []string{"state=DONE"}, false},
+	}
+	for _, test := range tests {
+		got, err := test.table.Match(test.until)
+		if err != nil || got != test.want {
+			t.Errorf("Match(%q) on %v = %v, %v, want %v", test.until, test.table.Rows, got, err, test.want)
+		}
+	}
+	if len(tb.Rows) != 2 {
+		t.Errorf("Match removed rows: %v", tb.Rows)
+	}
+}
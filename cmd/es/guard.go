@@ -0,0 +1,330 @@
+package es
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hebe/langs/goreq"
+	"os"
+	"os/user"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
+)
+
+// Guardrails of the cluster profiles, checked by the client before each request:
+//
+//   - read_only profiles refuse any request changing the cluster,
+//   - protected_indices patterns refuse requests changing the indices they match,
+//   - destructive requests on profiles tagged production need the cluster name typed,
+//     or given with --confirm-cluster,
+//   - deleting indices by wildcard pattern needs --allow-wildcard,
+//
+// and requests changing the cluster are appended to the audit_log file of the config.
+
+// readEndpoints are the endpoints POSTed to or DELETEd from without changing the cluster, by the
+// first segment of the path starting with _, or for those under another API, by both segments.
+var readEndpoints = map[string]bool{
+	"_search":                         true,
+	"_msearch":                        true,
+	"_count":                          true,
+	"_mget":                           true,
+	"_pit":                            true,
+	"_validate":                       true,
+	"_explain":                        true,
+	"_field_caps":                     true,
+	"_analyze":                        true,
+	"_termvectors":                    true,
+	"_mtermvectors":                   true,
+	"_rank_eval":                      true,
+	"_render":                         true,
+	"_sql":                            true,
+	"_cluster/explain":                true,
+	"_ingest/_simulate":               true,
+	"_index_template/_simulate":       true,
+	"_index_template/_simulate_index": true,
+	"_snapshot/_verify":               true,
+}
+
+// destructiveEndpoints are the endpoints losing data or availability, along with DELETE requests.
+var destructiveEndpoints = map[string]bool{
+	"_close":           true,
+	"_freeze":          true,
+	"_delete_by_query": true,
+	"_restore":         true,
+}
+
+// pathSegments splits a request path, without its query string.
+func pathSegments(p string) []string {
+	p = strings.SplitN(p, "?", 2)[0]
+	return strings.Split(strings.Trim(p, "/"), "/")
+}
+
+// endpoint returns the API a request path calls: its first segment starting with _, as index
+// names cannot but _all and ids only come after it, followed by the segment of a read API under
+// it, e.g. _snapshot/_verify for _snapshot/repo/_verify.
+func endpoint(p string) string {
+	segments := pathSegments(p)
+	for i, s := range segments {
+		if !strings.HasPrefix(s, "_") || i == 0 && s == "_all" {
+			continue
+		}
+		for _, sub := range segments[i+1:] {
+			if readEndpoints[s+"/"+sub] {
+				return s + "/" + sub
+			}
+		}
+		return s
+	}
+	return ""
+}
+
+// mutating tells whether a request changes the cluster.
+func mutating(method string, p string) bool {
+	if method == goreq.GET || method == goreq.HEAD {
+		return false
+	}
+	return !readEndpoints[endpoint(p)]
+}
+
+// destructive tells whether a request deletes data or makes it unavailable.
+func destructive(method string, p string) bool {
+	if !mutating(method, p) {
+		return false
+	}
+	if method == goreq.DELETE {
+		return true
+	}
+	for _, s := range pathSegments(p) {
+		if destructiveEndpoints[s] {
+			return true
+		}
+	}
+	return false
+}
+
+// pathIndices returns the indices or patterns a request path starts with, _all included.
+func pathIndices(p string) []string {
+	first := pathSegments(p)[0]
+	if first == "" || strings.HasPrefix(first, "_") && first != "_all" {
+		return nil
+	}
+	return strings.Split(first, ",")
+}
+
+// bodyIndices returns the indices or patterns the body of a request changes, for the APIs
+// naming them there: _aliases, _restore, the destination of _reindex and the actions of _bulk.
+func bodyIndices(p string, body interface{}) []string {
+	segments := pathSegments(p)
+	api := segments[len(segments)-1]
+	switch api {
+	case "_aliases", "_restore", "_reindex", "_bulk":
+	default:
+		return nil
+	}
+	var data []byte
+	switch b := body.(type) {
+	case nil:
+		return nil
+	case string:
+		data = []byte(b)
+	default:
+		data, _ = json.Marshal(b)
+	}
+	var indices []string
+	switch api {
+	case "_aliases":
+		var req struct {
+			Actions []map[string]struct {
+				Index   interface{} `json:"index"`
+				Indices interface{} `json:"indices"`
+			} `json:"actions"`
+		}
+		json.Unmarshal(data, &req)
+		for _, action := range req.Actions {
+			for _, a := range action {
+				indices = append(indices, listedIndices(a.Index)...)
+				indices = append(indices, listedIndices(a.Indices)...)
+			}
+		}
+	case "_restore":
+		var req struct {
+			Indices           interface{} `json:"indices"`
+			RenamePattern     string      `json:"rename_pattern"`
+			RenameReplacement string      `json:"rename_replacement"`
+		}
+		json.Unmarshal(data, &req)
+		// all the indices of the snapshot are restored unless listed
+		indices = listedIndices(req.Indices)
+		if len(indices) == 0 {
+			indices = []string{"*"}
+		}
+		if re, err := regexp.Compile(req.RenamePattern); err == nil && req.RenamePattern != "" {
+			for i, index := range indices {
+				indices[i] = re.ReplaceAllString(index, req.RenameReplacement)
+			}
+		}
+	case "_reindex":
+		var req struct {
+			Dest struct {
+				Index string `json:"index"`
+			} `json:"dest"`
+		}
+		json.Unmarshal(data, &req)
+		indices = append(indices, req.Dest.Index)
+	case "_bulk":
+		lines := strings.Split(string(data), "\n")
+		for i := 0; i < len(lines); i++ {
+			var action map[string]struct {
+				Index string `json:"_index"`
+			}
+			if json.Unmarshal([]byte(lines[i]), &action) != nil {
+				continue
+			}
+			for op, meta := range action {
+				indices = append(indices, meta.Index)
+				// the source line following the action
+				if op != "delete" {
+					i++
+				}
+			}
+		}
+	}
+	return indices
+}
+
+// listedIndices returns the names of a list of indices given as a JSON array or a comma separated
+// string, without the excluded ones.
+func listedIndices(v interface{}) []string {
+	var names []string
+	switch v := v.(type) {
+	case string:
+		names = strings.Split(v, ",")
+	case []interface{}:
+		for _, name := range v {
+			names = append(names, fmt.Sprint(name))
+		}
+	}
+	var indices []string
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" && !strings.HasPrefix(name, "-") {
+			indices = append(indices, name)
+		}
+	}
+	return indices
+}
+
+func isWildcard(index string) bool {
+	return strings.ContainsAny(index, "*?") || index == "_all"
+}
+
+// protects tells whether a protected pattern matches index, or when index is a pattern, could match the same indices.
+func protects(pattern string, index string) bool {
+	if ok, _ := path.Match(pattern, index); ok {
+		return true
+	}
+	if isWildcard(index) {
+		ok, _ := path.Match(index, pattern)
+		return ok || index == "_all"
+	}
+	return false
+}
+
+// guard checks a request against the guardrails of the cluster. Indices are told from the path
+// and from the bodies of the APIs naming them there; others, e.g. scripts of update by query
+// writing to other indices, are not inspected.
+func (c *Client) guard(method string, p string, body interface{}) error {
+	if !mutating(method, p) {
+		return nil
+	}
+	name := c.cluster.Name
+	if c.cluster.ReadOnly {
+		return fmt.Errorf("cluster %s is read only, refusing %s %s", name, method, p)
+	}
+	for _, index := range append(pathIndices(p), bodyIndices(p, body)...) {
+		if index == "" {
+			continue
+		}
+		for _, pattern := range c.cluster.ProtectedIndices {
+			if protects(pattern, index) {
+				return fmt.Errorf("%s is protected by %s on cluster %s, refusing %s %s", index, pattern, name, method, p)
+			}
+		}
+		if method == goreq.DELETE && isWildcard(index) && !guardFlags.allowWildcard {
+			return fmt.Errorf("refusing to delete %s by wildcard without --allow-wildcard", index)
+		}
+	}
+	if destructive(method, p) && c.cluster.hasTag("production") {
+		return c.confirmProduction(method, p)
+	}
+	return nil
+}
+
+// confirmProduction has the name of the cluster typed once before the first destructive request.
+func (c *Client) confirmProduction(method string, p string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	name := c.cluster.Name
+	switch {
+	case c.confirmed:
+		return nil
+	case guardFlags.confirmCluster != "":
+		if guardFlags.confirmCluster != name {
+			return fmt.Errorf("--confirm-cluster %s does not match cluster %s", guardFlags.confirmCluster, name)
+		}
+	case !isTerminal(os.Stdin):
+		return fmt.Errorf("%s is a production cluster, confirm %s %s with --confirm-cluster %s", name, method, p, name)
+	default:
+		fmt.Fprintf(os.Stderr, "%s is a production cluster, type its name to %s %s: ", name, method, p)
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.TrimSpace(line) != name {
+			return errors.New("cluster name does not match, aborted")
+		}
+	}
+	c.confirmed = true
+	return nil
+}
+
+var auditMu sync.Mutex
+
+// audit appends a request changing the cluster to the audit log, when one is configured.
+func (c *Client) audit(method string, p string, status int) {
+	file := viper.GetString("audit_log")
+	if file == "" {
+		return
+	}
+	name, err := homedir.Expand(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: audit log: %v\n", err)
+		return
+	}
+	username := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+	line, _ := json.Marshal(struct {
+		Time    string `json:"time"`
+		User    string `json:"user"`
+		Cluster string `json:"cluster"`
+		Method  string `json:"method"`
+		Path    string `json:"path"`
+		Status  int    `json:"status"`
+	}{time.Now().Format(time.RFC3339), username, c.cluster.Name, method, "/" + strings.TrimPrefix(p, "/"), status})
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err == nil {
+		_, err = f.Write(append(line, '\n'))
+		f.Close()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: audit log: %v\n", err)
+	}
+}
@@ -0,0 +1,219 @@
+package es
+
+import (
+	"hebe/langs/goreq"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMutating(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   bool
+	}{
+		{goreq.GET, "logs/_doc/1", false},
+		{goreq.HEAD, "logs", false},
+		{goreq.POST, "logs/_search", false},
+		{goreq.POST, "logs-*/_search?scroll=5m", false},
+		{goreq.DELETE, "_search/scroll", false},
+		{goreq.DELETE, "_pit", false},
+		{goreq.POST, "logs/_count", false},
+		{goreq.POST, "logs/_explain/1", false},
+		{goreq.POST, "_cluster/allocation/explain", false},
+		{goreq.POST, "_snapshot/backups/_verify", false},
+		{goreq.POST, "_ingest/pipeline/logs/_simulate", false},
+		{goreq.POST, "_index_template/_simulate_index/logs-1", false},
+		{goreq.POST, "_sql?format=txt", false},
+		{goreq.POST, "_all/_search", false},
+		{goreq.POST, "_all/_count", false},
+		{goreq.POST, "_all/_pit?keep_alive=1m", false},
+		{goreq.PUT, "logs", true},
+		{goreq.POST, "logs/_doc", true},
+		{goreq.DELETE, "logs", true},
+		{goreq.POST, "_aliases", true},
+		{goreq.PUT, "_snapshot/backups", true},
+		// ids and indices named after read endpoints
+		{goreq.POST, "logs/_doc/explain", true},
+		{goreq.PUT, "logs/_doc/_search", true},
+		{goreq.PUT, "explain", true},
+		{goreq.POST, "search/_doc", true},
+		{goreq.DELETE, "logs/_doc/_count", true},
+	}
+	for _, test := range tests {
+		if got := mutating(test.method, test.path); got != test.want {
+			t.Errorf("mutating(%s %s) = %v, want %v", test.method, test.path, got, test.want)
+		}
+	}
+}
+
+func TestDestructive(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   bool
+	}{
+		{goreq.DELETE, "logs", true},
+		{goreq.DELETE, "_snapshot/backups/nightly", true},
+		{goreq.POST, "logs/_close", true},
+		{goreq.POST, "logs/_freeze", true},
+		{goreq.POST, "logs/_delete_by_query?conflicts=proceed", true},
+		{goreq.POST, "_snapshot/backups/nightly/_restore", true},
+		{goreq.DELETE, "_search/scroll", false},
+		{goreq.DELETE, "_pit", false},
+		{goreq.POST, "logs/_open", false},
+		{goreq.PUT, "logs/_settings", false},
+		{goreq.GET, "logs/_close", false},
+	}
+	for _, test := range tests {
+		if got := destructive(test.method, test.path); got != test.want {
+			t.Errorf("destructive(%s %s) = %v, want %v", test.method, test.path, got, test.want)
+		}
+	}
+}
+
+func TestProtects(t *testing.T) {
+	tests := []struct {
+		pattern string
+		index   string
+		want    bool
+	}{
+		{"billing-*", "billing-2026", true},
+		{"billing-*", "logs-2026", false},
+		{".security*", ".security-7", true},
+		{"billing-*", "*", true},
+		{"billing-*", "bill*", true},
+		{"billing-*", "logs*", false},
+		{"billing-*", "_all", true},
+		{"billing-2026", "billing-*", true},
+		{"billing-2026", "billing-2026", true},
+		{"billing-2026", "logs-*", false},
+	}
+	for _, test := range tests {
+		if got := protects(test.pattern, test.index); got != test.want {
+			t.Errorf("protects(%q, %q) = %v, want %v", test.pattern, test.index, got, test.want)
+		}
+	}
+}
+
+func TestBodyIndices(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		body interface{}
+		want []string
+	}{
+		{
+			name: "aliases",
+			path: "_aliases",
+			body: map[string]interface{}{"actions": []interface{}{
+				map[string]interface{}{"add": map[string]interface{}{"index": "logs-2", "alias": "logs"}},
+				map[string]interface{}{"remove": map[string]interface{}{"indices": []interface{}{"logs-1", "logs-0"}, "alias": "logs"}},
+				map[string]interface{}{"remove_index": map[string]interface{}{"index": "billing-1"}},
+			}},
+			want: []string{"logs-2", "logs-1", "logs-0", "billing-1"},
+		},
+		{
+			name: "aliases as a string",
+			path: "/_aliases",
+			body: `{"actions": [{"remove_index": {"index": "billing-1"}}]}`,
+			want: []string{"billing-1"},
+		},
+		{
+			name: "restore",
+			path: "_snapshot/backups/nightly/_restore?wait_for_completion=true",
+			body: map[string]interface{}{"indices": "billing-1, logs-*,-logs-old"},
+			want: []string{"billing-1", "logs-*"},
+		},
+		{
+			name: "restore renamed",
+			path: "_snapshot/backups/nightly/_restore",
+			body: map[string]interface{}{"indices": []interface{}{"billing-1"}, "rename_pattern": "(.+)", "rename_replacement": "restored-$1"},
+			want: []string{"restored-billing-1"},
+		},
+		{
+			name: "restore all",
+			path: "_snapshot/backups/nightly/_restore",
+			body: map[string]interface{}{},
+			want: []string{"*"},
+		},
+		{
+			name: "reindex",
+			path: "_reindex",
+			body: map[string]interface{}{"source": map[string]interface{}{"index": "logs"}, "dest": map[string]interface{}{"index": "billing-1"}},
+			want: []string{"billing-1"},
+		},
+		{
+			name: "bulk",
+			path: "logs/_bulk",
+			body: `{"index":{"_index":"billing-1","_id":"1"}}
+{"message":"hello"}
+{"delete":{"_index":"billing-2"}}
+{"delete":{"_index":"billing-3"}}
+{"update":{"_id":"2"}}
+{"doc":{"_index":"not-an-action"}}
+{"create":{"_index":"billing-4"}}
+{"index":{"_index":"not-an-action"}}
+`,
+			want: []string{"billing-1", "billing-2", "billing-3", "", "billing-4"},
+		},
+		{name: "no body", path: "_aliases"},
+		{name: "other API", path: "logs/_update_by_query", body: map[string]interface{}{"query": map[string]interface{}{}}},
+	}
+	for _, test := range tests {
+		if got := bodyIndices(test.path, test.body); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: bodyIndices(%s) = %q, want %q", test.name, test.path, got, test.want)
+		}
+	}
+}
+
+func TestGuard(t *testing.T) {
+	protected := &Client{cluster: &Cluster{Name: "logs", ProtectedIndices: []string{"billing-*"}}}
+	readOnly := &Client{cluster: &Cluster{Name: "replica", ReadOnly: true}}
+	tests := []struct {
+		name   string
+		client *Client
+		method string
+		path   string
+		body   interface{}
+		err    string
+	}{
+		{name: "read only search", client: readOnly, method: goreq.POST, path: "logs/_search"},
+		{name: "read only write", client: readOnly, method: goreq.PUT, path: "logs/_doc/1", err: "read only"},
+		{name: "unprotected", client: protected, method: goreq.PUT, path: "logs/_doc/1"},
+		{name: "protected", client: protected, method: goreq.PUT, path: "billing-2026/_doc/1", err: "protected by billing-*"},
+		{name: "protected in a list", client: protected, method: goreq.POST, path: "logs,billing-1/_close", err: "protected"},
+		{name: "protected by pattern", client: protected, method: goreq.POST, path: "*/_refresh", err: "protected"},
+		{name: "protected search", client: protected, method: goreq.POST, path: "billing-*/_search"},
+		{name: "read only search of all", client: readOnly, method: goreq.POST, path: "_all/_search"},
+		{name: "protected search of all", client: protected, method: goreq.POST, path: "_all/_count"},
+		{
+			name: "protected alias", client: protected, method: goreq.POST, path: "_aliases",
+			body: map[string]interface{}{"actions": []interface{}{map[string]interface{}{"remove_index": map[string]interface{}{"index": "billing-1"}}}},
+			err:  "billing-1 is protected",
+		},
+		{
+			name: "protected restore", client: protected, method: goreq.POST, path: "_snapshot/backups/nightly/_restore",
+			body: map[string]interface{}{"indices": "billing-1"},
+			err:  "billing-1 is protected",
+		},
+		{
+			name: "restore renamed", client: protected, method: goreq.POST, path: "_snapshot/backups/nightly/_restore",
+			body: map[string]interface{}{"indices": "billing-1", "rename_pattern": "billing-(.+)", "rename_replacement": "restored-$1"},
+		},
+		{name: "wildcard delete", client: protected, method: goreq.DELETE, path: "logs-*", err: "--allow-wildcard"},
+		{name: "delete all", client: protected, method: goreq.DELETE, path: "_all", err: "protected"},
+		{name: "close all", client: &Client{cluster: &Cluster{Name: "logs"}}, method: goreq.POST, path: "_all/_close"},
+		{name: "delete all by wildcard", client: &Client{cluster: &Cluster{Name: "logs"}}, method: goreq.DELETE, path: "_all", err: "--allow-wildcard"},
+	}
+	for _, test := range tests {
+		err := test.client.guard(test.method, test.path, test.body)
+		if test.err == "" && err != nil {
+			t.Errorf("%s: guard(%s %s): %v", test.name, test.method, test.path, err)
+		}
+		if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%s: guard(%s %s) = %v, want an error with %q", test.name, test.method, test.path, err, test.err)
+		}
+	}
+}
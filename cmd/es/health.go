@@ -38,6 +38,6 @@ to quickly create a Cobra application.`,
 
 func init() {
 	EsCmd.AddCommand(healthCmd)
-
-	healthCmd.Flags().StringP("cluster", "c", "localhost:9200", "es cluster")
+	addCatFlags(healthCmd)
+	addCheckFlags(healthCmd)
 }
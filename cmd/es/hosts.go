@@ -0,0 +1,122 @@
+package es
+
+import (
+	"encoding/json"
+	"hebe/langs/goreq"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	minDeadTimeout = time.Second
+	maxDeadTimeout = time.Minute
+)
+
+// host is one HTTP endpoint of a cluster.
+type host struct {
+	url       string
+	failures  uint
+	deadUntil time.Time
+}
+
+// hostPool round-robins requests across the hosts of a cluster, skipping hosts
+// which failed recently. A host which keeps failing is skipped for twice as long
+// each time, up to maxDeadTimeout.
+type hostPool struct {
+	mu    sync.Mutex
+	hosts []*host
+	next  int
+}
+
+func newHostPool(urls []string) *hostPool {
+	p := &hostPool{}
+	p.set(urls)
+	return p
+}
+
+func (p *hostPool) set(urls []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.hosts = make([]*host, len(urls))
+	for i, u := range urls {
+		p.hosts[i] = &host{url: u}
+	}
+	p.next = 0
+}
+
+func (p *hostPool) size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.hosts)
+}
+
+// pick returns the next live host, or the host coming back soonest when all of them are dead.
+func (p *hostPool) pick() *host {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	var soonest *host
+	for i := 0; i < len(p.hosts); i++ {
+		h := p.hosts[(p.next+i)%len(p.hosts)]
+		if !h.deadUntil.After(now) {
+			p.next = (p.next + i + 1) % len(p.hosts)
+			return h
+		}
+		if soonest == nil || h.deadUntil.Before(soonest.deadUntil) {
+			soonest = h
+		}
+	}
+	return soonest
+}
+
+func (p *hostPool) markDead(h *host) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	timeout := minDeadTimeout << h.failures
+	if timeout > maxDeadTimeout || timeout <= 0 {
+		timeout = maxDeadTimeout
+	}
+	h.failures++
+	h.deadUntil = time.Now().Add(timeout)
+}
+
+func (p *hostPool) markAlive(h *host) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h.failures = 0
+	h.deadUntil = time.Time{}
+}
+
+// sniff replaces the hosts of the client with the HTTP publish addresses of the cluster nodes.
+func (c *Client) sniff() error {
+	body, err := c.Request(goreq.GET, "_nodes/http?filter_path=nodes.*.http.publish_address", nil)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Nodes map[string]struct {
+			HTTP struct {
+				PublishAddress string `json:"publish_address"`
+			} `json:"http"`
+		} `json:"nodes"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return err
+	}
+	var urls []string
+	for _, node := range resp.Nodes {
+		addr := node.HTTP.PublishAddress
+		// publish_address is either ip:port or hostname/ip:port
+		if i := strings.LastIndex(addr, "/"); i >= 0 {
+			addr = addr[i+1:]
+		}
+		if addr != "" {
+			urls = append(urls, c.cluster.baseURL(addr))
+		}
+	}
+	if len(urls) > 0 {
+		c.hosts.set(urls)
+	}
+	return nil
+}
@@ -0,0 +1,505 @@
+/*
+Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package es
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hebe/langs/goreq"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// ilmCmd represents the es command
+var ilmCmd = &cobra.Command{
+	Use:   "ilm",
+	Short: "Manage lifecycle policies and explain where indices are in them",
+	Long: `Manage index lifecycle management (ILM) policies, or the index state management (ISM)
+policies of OpenSearch clusters, and explain which phase, action and step of its policy
+each index is in, with the error of the indices stuck in the ERROR step, e.g.
+
+  hebe es ilm list
+  hebe es ilm show logs > logs-policy.json
+  hebe es ilm put logs -f logs-policy.json
+  hebe es ilm explain 'logs-*' --only-errors
+  hebe es ilm retry 'logs-*'
+
+The phases of ISM policies are their states.`,
+}
+
+func init() {
+	EsCmd.AddCommand(ilmCmd)
+	addConfirmFlags(ilmCmd)
+	ilmCmd.AddCommand(ilmListCmd, ilmShowCmd, ilmPutCmd, ilmDeleteCmd, ilmExplainCmd, ilmRetryCmd)
+	ilmPutCmd.Flags().StringP("file", "f", "-", "JSON file of the policy, - for stdin")
+	ilmExplainCmd.Flags().Bool("only-errors", false, "only show the indices in the ERROR step")
+	ilmExplainCmd.Flags().Bool("only-managed", false, "only show the indices managed by a policy")
+}
+
+// ilmPhases in the order indices go through them.
+var ilmPhases = []string{"hot", "warm", "cold", "frozen", "delete"}
+
+// usesISM tells whether the cluster manages lifecycles with ISM rather than ILM.
+func usesISM(client *Client) (bool, error) {
+	flavor, err := client.flavor()
+	return flavor == "opensearch", err
+}
+
+// lifecyclePolicy is an ILM or ISM policy, def being the body put accepts.
+type lifecyclePolicy struct {
+	name     string
+	version  interface{}
+	modified interface{}
+	phases   []string
+	indices  interface{}
+	def      map[string]interface{}
+	// the sequence number and primary term ISM requires to update a policy
+	seqNo       json.Number
+	primaryTerm json.Number
+}
+
+// ismReadOnly are the fields of the ISM policies returned but not put.
+var ismReadOnly = []string{"policy_id", "last_updated_time", "schema_version"}
+
+// getLifecyclePolicies returns the policies of the cluster, or the one named name, sorted by name.
+func getLifecyclePolicies(client *Client, ism bool, name string) ([]lifecyclePolicy, error) {
+	var policies []lifecyclePolicy
+	var err error
+	if ism {
+		policies, err = getISMPolicies(client, name)
+	} else {
+		policies, err = getILMPolicies(client, name)
+	}
+	if e, ok := err.(*ResponseError); ok && e.Status == 404 && name != "" {
+		return nil, fmt.Errorf("policy %s not found", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(policies, func(i, j int) bool { return policies[i].name < policies[j].name })
+	return policies, nil
+}
+
+// getILMPolicies returns the ILM policies, or the one named.
+func getILMPolicies(client *Client, name string) ([]lifecyclePolicy, error) {
+	p := "_ilm/policy"
+	if name != "" {
+		p += "/" + url.PathEscape(name)
+	}
+	data, err := client.Request(goreq.GET, p, nil)
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var policies []lifecyclePolicy
+	var resp map[string]struct {
+		Version      interface{}            `json:"version"`
+		ModifiedDate interface{}            `json:"modified_date"`
+		Policy       map[string]interface{} `json:"policy"`
+		InUseBy      *struct {
+			Indices []string `json:"indices"`
+		} `json:"in_use_by"`
+	}
+	if err := d.Decode(&resp); err != nil {
+		return nil, err
+	}
+	for name, ip := range resp {
+		policy := lifecyclePolicy{name: name, version: ip.Version, modified: ip.ModifiedDate}
+		phases, _ := ip.Policy["phases"].(map[string]interface{})
+		for _, phase := range ilmPhases {
+			if _, ok := phases[phase]; ok {
+				policy.phases = append(policy.phases, phase)
+			}
+		}
+		// only returned by recent versions
+		if ip.InUseBy != nil {
+			policy.indices = len(ip.InUseBy.Indices)
+		}
+		policy.def = map[string]interface{}{"policy": ip.Policy}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// ismPageSize is the number of ISM policies listed per request, the API returning 20 by default.
+const ismPageSize = 1000
+
+// getISMPolicies returns the ISM policies, page by page until total_policies, or the one named.
+func getISMPolicies(client *Client, name string) ([]lifecyclePolicy, error) {
+	type ismPolicy struct {
+		ID          string                 `json:"_id"`
+		SeqNo       json.Number            `json:"_seq_no"`
+		PrimaryTerm json.Number            `json:"_primary_term"`
+		Policy      map[string]interface{} `json:"policy"`
+	}
+	var policies []lifecyclePolicy
+	for from := 0; ; {
+		p := fmt.Sprintf("_plugins/_ism/policies?size=%d&from=%d", ismPageSize, from)
+		if name != "" {
+			p = "_plugins/_ism/policies/" + url.PathEscape(name)
+		}
+		data, err := client.Request(goreq.GET, p, nil)
+		if err != nil {
+			return nil, err
+		}
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.UseNumber()
+		var resp struct {
+			ismPolicy
+			Policies      []ismPolicy `json:"policies"`
+			TotalPolicies int         `json:"total_policies"`
+		}
+		if err := d.Decode(&resp); err != nil {
+			return nil, err
+		}
+		if name != "" {
+			resp.Policies = []ismPolicy{resp.ismPolicy}
+		}
+		for _, ip := range resp.Policies {
+			policy := lifecyclePolicy{name: ip.ID, seqNo: ip.SeqNo, primaryTerm: ip.PrimaryTerm}
+			if ms, err := json.Number(cell(ip.Policy["last_updated_time"])).Int64(); err == nil {
+				policy.modified = time.Unix(0, ms*int64(time.Millisecond)).UTC().Format(time.RFC3339)
+			}
+			states, _ := ip.Policy["states"].([]interface{})
+			for _, s := range states {
+				s, _ := s.(map[string]interface{})
+				policy.phases = append(policy.phases, cell(s["name"]))
+			}
+			for _, k := range ismReadOnly {
+				delete(ip.Policy, k)
+			}
+			policy.def = map[string]interface{}{"policy": ip.Policy}
+			policies = append(policies, policy)
+		}
+		from += len(resp.Policies)
+		if name != "" || len(resp.Policies) == 0 || from >= resp.TotalPolicies {
+			return policies, nil
+		}
+	}
+}
+
+// lifecycleState is where an index is in its ILM or ISM policy.
+type lifecycleState struct {
+	index      string
+	policy     string
+	phase      string
+	action     string
+	step       string
+	failedStep string
+	since      time.Time
+	retries    int
+	info       string
+}
+
+func (s lifecycleState) failed() bool {
+	return s.step == "ERROR"
+}
+
+// explainLifecycle returns the lifecycle state of the indices matching pattern, sorted by index.
+func explainLifecycle(client *Client, ism bool, pattern string) ([]lifecycleState, error) {
+	p := indexPath(strings.Split(pattern, ",")) + "/_ilm/explain"
+	if ism {
+		p = "_plugins/_ism/explain/" + indexPath(strings.Split(pattern, ","))
+	}
+	data, err := client.Request(goreq.GET, p, nil)
+	if err != nil {
+		return nil, err
+	}
+	var states []lifecycleState
+	if ism {
+		// the indices along with total_managed_indices
+		var resp map[string]json.RawMessage
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return nil, err
+		}
+		for index, raw := range resp {
+			var e struct {
+				PolicyID string `json:"policy_id"`
+				State    struct {
+					Name string `json:"name"`
+				} `json:"state"`
+				Action struct {
+					Name            string `json:"name"`
+					Failed          bool   `json:"failed"`
+					ConsumedRetries int    `json:"consumed_retries"`
+				} `json:"action"`
+				Step struct {
+					Name       string `json:"name"`
+					StartTime  int64  `json:"start_time"`
+					StepStatus string `json:"step_status"`
+				} `json:"step"`
+				RetryInfo struct {
+					Failed bool `json:"failed"`
+				} `json:"retry_info"`
+				Info map[string]interface{} `json:"info"`
+			}
+			if json.Unmarshal(raw, &e) != nil {
+				continue
+			}
+			s := lifecycleState{index: index, policy: e.PolicyID, phase: e.State.Name, action: e.Action.Name, step: e.Step.Name, retries: e.Action.ConsumedRetries}
+			if e.Step.StartTime > 0 {
+				s.since = time.Unix(0, e.Step.StartTime*int64(time.Millisecond))
+			}
+			if e.Action.Failed || e.RetryInfo.Failed || e.Step.StepStatus == "failed" {
+				s.step, s.failedStep = "ERROR", e.Step.Name
+			}
+			s.info = cell(e.Info["message"])
+			if cause := cell(e.Info["cause"]); cause != "" {
+				s.info += ": " + cause
+			}
+			states = append(states, s)
+		}
+	} else {
+		var resp struct {
+			Indices map[string]struct {
+				Policy               string                 `json:"policy"`
+				Phase                string                 `json:"phase"`
+				Action               string                 `json:"action"`
+				Step                 string                 `json:"step"`
+				FailedStep           string                 `json:"failed_step"`
+				StepTimeMillis       int64                  `json:"step_time_millis"`
+				FailedStepRetryCount int                    `json:"failed_step_retry_count"`
+				StepInfo             map[string]interface{} `json:"step_info"`
+			} `json:"indices"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return nil, err
+		}
+		for index, e := range resp.Indices {
+			s := lifecycleState{index: index, policy: e.Policy, phase: e.Phase, action: e.Action, step: e.Step, failedStep: e.FailedStep, retries: e.FailedStepRetryCount}
+			if e.StepTimeMillis > 0 {
+				s.since = time.Unix(0, e.StepTimeMillis*int64(time.Millisecond))
+			}
+			// the error of a failed step, or why a step waits, e.g. for the conditions of a rollover
+			switch {
+			case e.StepInfo["reason"] != nil:
+				s.info = cell(e.StepInfo["reason"])
+			case e.StepInfo["message"] != nil:
+				s.info = cell(e.StepInfo["message"])
+			case len(e.StepInfo) > 0:
+				s.info = cell(e.StepInfo)
+			}
+			states = append(states, s)
+		}
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].index < states[j].index })
+	return states, nil
+}
+
+// lifecycleTable renders lifecycle states, the time since the current step began in since.
+func lifecycleTable(states []lifecycleState) *Table {
+	t := &Table{Columns: []string{"index", "policy", "phase", "action", "step", "failed_step", "since", "retries", "info"}}
+	for _, s := range states {
+		var since, retries interface{}
+		if !s.since.IsZero() {
+			since = time.Since(s.since).Round(time.Second).String()
+		}
+		if s.policy != "" {
+			retries = s.retries
+		}
+		t.Rows = append(t.Rows, []interface{}{s.index, s.policy, s.phase, s.action, s.step, s.failedStep, since, retries, s.info})
+	}
+	return t
+}
+
+var ilmListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List policies",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		client, err := newClient(cluster)
+		check(err)
+		ism, err := usesISM(client)
+		check(err)
+		policies, err := getLifecyclePolicies(client, ism, "")
+		check(err)
+		t := &Table{Columns: []string{"name", "version", "modified", "phases", "indices"}}
+		for _, p := range policies {
+			t.Rows = append(t.Rows, []interface{}{p.name, p.version, p.modified, strings.Join(p.phases, ","), p.indices})
+		}
+		check(t.Write(os.Stdout, outputFormat))
+	},
+}
+
+var ilmShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Print the definition of a policy, as accepted by put",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		client, err := newClient(cluster)
+		check(err)
+		ism, err := usesISM(client)
+		check(err)
+		policies, err := getLifecyclePolicies(client, ism, args[0])
+		check(err)
+		if len(policies) == 0 {
+			check(fmt.Errorf("policy %s not found", args[0]))
+		}
+		data, err := json.MarshalIndent(policies[0].def, "", "  ")
+		check(err)
+		fmt.Printf("%s\n", data)
+	},
+}
+
+var ilmPutCmd = &cobra.Command{
+	Use:   "put <name>",
+	Short: "Create or replace a policy",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		file, err := cmd.Flags().GetString("file")
+		check(err)
+		body, err := readJSON(file)
+		check(err)
+		// the policy alone, as well as the body of the API
+		if _, ok := body["policy"]; !ok {
+			body = map[string]interface{}{"policy": body}
+		}
+		client, err := newClient(cluster)
+		check(err)
+		ism, err := usesISM(client)
+		check(err)
+		path := "_ilm/policy/" + url.PathEscape(args[0])
+		if ism {
+			path = "_plugins/_ism/policies/" + url.PathEscape(args[0])
+			// replacing a policy needs its sequence number
+			if current, err := getLifecyclePolicies(client, ism, args[0]); err == nil && len(current) == 1 {
+				query := url.Values{"if_seq_no": {current[0].seqNo.String()}, "if_primary_term": {current[0].primaryTerm.String()}}
+				path += "?" + query.Encode()
+			}
+		}
+		if confirmFlags.dryRun {
+			printRequest(goreq.PUT, path, body)
+			return
+		}
+		_, err = client.Request(goreq.PUT, path, body)
+		check(err)
+		fmt.Printf("put policy %s\n", args[0])
+	},
+}
+
+var ilmDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a policy",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		client, err := newClient(cluster)
+		check(err)
+		ism, err := usesISM(client)
+		check(err)
+		_, err = getLifecyclePolicies(client, ism, args[0])
+		check(err)
+		path := "_ilm/policy/" + url.PathEscape(args[0])
+		if ism {
+			path = "_plugins/_ism/policies/" + url.PathEscape(args[0])
+		}
+		if confirmFlags.dryRun {
+			printRequest(goreq.DELETE, path, nil)
+			return
+		}
+		// production clusters have their name typed instead
+		if !client.cluster.hasTag("production") {
+			ok, err := confirm(fmt.Sprintf("Delete policy %s?", args[0]), confirmFlags.yes)
+			check(err)
+			if !ok {
+				os.Exit(exitError)
+			}
+		}
+		_, err = client.Request(goreq.DELETE, path, nil)
+		check(err)
+		fmt.Printf("deleted policy %s\n", args[0])
+	},
+}
+
+var ilmExplainCmd = &cobra.Command{
+	Use:   "explain <index>",
+	Short: "Show the phase, action and step of indices in their policy, and the errors of failed steps",
+	Long: `Show the phase, action and step of the indices matching a pattern in their policy, how
+long ago the step began, and in info the error of the indices in the ERROR step, along
+with the step which failed, or why a step waits, e.g. for the conditions of a rollover.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		onlyErrors, err := cmd.Flags().GetBool("only-errors")
+		check(err)
+		onlyManaged, err := cmd.Flags().GetBool("only-managed")
+		check(err)
+		client, err := newClient(cluster)
+		check(err)
+		ism, err := usesISM(client)
+		check(err)
+		states, err := explainLifecycle(client, ism, args[0])
+		check(err)
+		var shown []lifecycleState
+		for _, s := range states {
+			if onlyErrors && !s.failed() || onlyManaged && s.policy == "" {
+				continue
+			}
+			shown = append(shown, s)
+		}
+		check(lifecycleTable(shown).Write(os.Stdout, outputFormat))
+	},
+}
+
+var ilmRetryCmd = &cobra.Command{
+	Use:   "retry <index>",
+	Short: "Retry the failed step of the indices in the ERROR step",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		client, err := newClient(cluster)
+		check(err)
+		ism, err := usesISM(client)
+		check(err)
+		states, err := explainLifecycle(client, ism, args[0])
+		check(err)
+		var failed []lifecycleState
+		var names []string
+		for _, s := range states {
+			if s.failed() {
+				failed = append(failed, s)
+				names = append(names, s.index)
+			}
+		}
+		if len(failed) == 0 {
+			fmt.Fprintf(os.Stderr, "no index matching %s is in the ERROR step\n", args[0])
+			return
+		}
+		if confirmFlags.dryRun {
+			check(lifecycleTable(failed).Write(os.Stdout, outputFormat))
+			return
+		}
+		for _, chunk := range indexChunks(names) {
+			path := indexPath(chunk) + "/_ilm/retry"
+			if ism {
+				path = "_plugins/_ism/retry/" + indexPath(chunk)
+			}
+			_, err = client.Request(goreq.POST, path, nil)
+			check(err)
+			fmt.Printf("retried %s\n", strings.Join(chunk, ", "))
+		}
+	},
+}
@@ -0,0 +1,380 @@
+/*
+Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package es
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hebe/langs/goreq"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// indexCmd represents the es command
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Create, delete, open, close, resize and roll over indices",
+	Long: `Act on indices. The commands taking index patterns first list the matching
+indices; --dry-run stops there, and delete, close, freeze and forcemerge ask for
+confirmation unless --yes is given, e.g.
+
+  hebe es index delete 'logs-2025.*' --dry-run
+  hebe es index delete 'logs-2025.*' --allow-wildcard
+  hebe es index close logs-2026.09.* --yes
+  hebe es index create logs-template-test -f index.json
+  hebe es index shrink logs-2026.09.01 logs-2026.09.01-shrunk --shards 1 --block-write
+  hebe es index rollover logs-write --max-age 1d --max-size 50gb`,
+}
+
+func init() {
+	EsCmd.AddCommand(indexCmd)
+	addConfirmFlags(indexCmd)
+
+	indexCmd.AddCommand(indexCreateCmd)
+	indexCreateCmd.Flags().StringP("file", "f", "", "JSON file with the settings, mappings and aliases of the index, - for stdin")
+	indexCreateCmd.Flags().Int("shards", 0, "number of primary shards")
+	indexCreateCmd.Flags().Int("replicas", -1, "number of replicas")
+
+	forcemerge := patternCommand("forcemerge", "Force merge the segments of indices", "force merged", goreq.POST, "_forcemerge", true)
+	forcemerge.Flags().Int("max-segments", 0, "number of segments to merge each shard down to")
+	forcemerge.Flags().Bool("only-expunge-deletes", false, "only merge segments with deleted documents")
+	indexCmd.AddCommand(
+		patternCommand("delete", "Delete indices", "deleted", goreq.DELETE, "", true),
+		patternCommand("open", "Open closed indices", "opened", goreq.POST, "_open", false),
+		patternCommand("close", "Close indices", "closed", goreq.POST, "_close", true),
+		patternCommand("freeze", "Freeze indices, before 8.0", "froze", goreq.POST, "_freeze", true),
+		patternCommand("unfreeze", "Unfreeze frozen indices", "unfroze", goreq.POST, "_unfreeze", false),
+		patternCommand("refresh", "Refresh indices", "refreshed", goreq.POST, "_refresh", false),
+		patternCommand("flush", "Flush indices", "flushed", goreq.POST, "_flush", false),
+		forcemerge,
+	)
+
+	for _, resize := range []string{"shrink", "split", "clone"} {
+		cmd := resizeCommand(resize)
+		indexCmd.AddCommand(cmd)
+		cmd.Flags().StringP("file", "f", "", "JSON file with the settings and aliases of the target index, - for stdin")
+		cmd.Flags().Bool("block-write", false, "block writes to the source index first, which resizing requires")
+		if resize != "clone" {
+			cmd.Flags().Int("shards", 0, "number of primary shards of the target index")
+		}
+	}
+
+	indexCmd.AddCommand(indexRolloverCmd)
+	indexRolloverCmd.Flags().String("max-age", "", "roll over when the index is older, e.g. 7d")
+	indexRolloverCmd.Flags().Int64("max-docs", 0, "roll over when the index has more documents")
+	indexRolloverCmd.Flags().String("max-size", "", "roll over when the primary shards are larger, e.g. 50gb")
+	indexRolloverCmd.Flags().String("max-primary-shard-size", "", "roll over when a primary shard is larger, e.g. 50gb")
+	indexRolloverCmd.Flags().StringP("file", "f", "", "JSON file with the conditions, settings, mappings and aliases of the new index, - for stdin")
+}
+
+// matchIndices lists the indices matching patterns.
+func matchIndices(client *Client, patterns []string) (*Table, error) {
+	names := url.PathEscape(strings.Join(patterns, ","))
+	t, err := callCatRequest(client, "indices/"+names, "h=index,health,status,pri,rep,docs.count,store.size", "s=index")
+	if err != nil {
+		return nil, err
+	}
+	if len(t.Rows) == 0 {
+		return nil, fmt.Errorf("no indices match %s", strings.Join(patterns, ","))
+	}
+	return t, nil
+}
+
+// maxIndexPath bounds the indices named in one request path, below the 4kb limit of the
+// request line of Elasticsearch, http.max_initial_line_length.
+const maxIndexPath = 3072
+
+// indexPath escapes the names of indices for a path, keeping the commas separating them.
+func indexPath(names []string) string {
+	escaped := make([]string, len(names))
+	for i, name := range names {
+		escaped[i] = url.PathEscape(name)
+	}
+	return strings.Join(escaped, ",")
+}
+
+// indexChunks splits the names of indices into chunks short enough to be named in one path.
+func indexChunks(names []string) [][]string {
+	var chunks [][]string
+	var chunk []string
+	size := 0
+	for _, name := range names {
+		n := len(url.PathEscape(name)) + 1
+		if len(chunk) > 0 && size+n > maxIndexPath {
+			chunks = append(chunks, chunk)
+			chunk, size = nil, 0
+		}
+		chunk = append(chunk, name)
+		size += n
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// patternCommand returns a command calling endpoint of the indices matching its
+// arguments, after confirmation when confirmed is set.
+func patternCommand(use string, short string, done string, method string, endpoint string, confirmed bool) *cobra.Command {
+	return &cobra.Command{
+		Use:   use + " <index pattern>...",
+		Short: short,
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			cluster := cmd.Flag("cluster").Value.String()
+			client, err := newClient(cluster)
+			check(err)
+			t, err := matchIndices(client, args)
+			check(err)
+			if confirmFlags.dryRun {
+				check(t.Write(os.Stdout, outputFormat))
+				return
+			}
+			if use == "delete" && !guardFlags.allowWildcard {
+				for _, pattern := range args {
+					if isWildcard(pattern) {
+						check(fmt.Errorf("refusing to delete %s by wildcard without --allow-wildcard", pattern))
+					}
+				}
+			}
+			names := make([]string, len(t.Rows))
+			for i := range t.Rows {
+				names[i] = t.Value(i, "index")
+			}
+			query := url.Values{}
+			if use == "forcemerge" {
+				if n, _ := cmd.Flags().GetInt("max-segments"); n > 0 {
+					query.Set("max_num_segments", fmt.Sprint(n))
+				}
+				if only, _ := cmd.Flags().GetBool("only-expunge-deletes"); only {
+					query.Set("only_expunge_deletes", "true")
+				}
+			}
+			var paths []string
+			chunks := indexChunks(names)
+			for _, chunk := range chunks {
+				path := indexPath(chunk)
+				if endpoint != "" {
+					path += "/" + endpoint
+				}
+				if len(query) > 0 {
+					path += "?" + query.Encode()
+				}
+				paths = append(paths, path)
+			}
+
+			if confirmed {
+				check(t.Write(os.Stderr, "table"))
+			}
+			// before asking, and on production clusters instead of asking
+			for _, path := range paths {
+				check(client.guard(method, path, nil))
+			}
+			if confirmed && !client.cluster.hasTag("production") {
+				ok, err := confirm(fmt.Sprintf("%s %d indices?", strings.Title(use), len(t.Rows)), confirmFlags.yes)
+				check(err)
+				if !ok {
+					os.Exit(exitError)
+				}
+			}
+			for i, path := range paths {
+				data, err := client.Request(method, path, nil)
+				check(err)
+				fmt.Printf("%s %s%s\n", done, strings.Join(chunks[i], ", "), shardsSummary(data))
+			}
+		},
+	}
+}
+
+// shardsSummary reports the shard failures of a broadcast response, e.g. of refresh.
+func shardsSummary(data []byte) string {
+	var resp struct {
+		Shards *struct {
+			Total      int `json:"total"`
+			Successful int `json:"successful"`
+			Failed     int `json:"failed"`
+		} `json:"_shards"`
+	}
+	if json.Unmarshal(data, &resp) != nil || resp.Shards == nil || resp.Shards.Failed == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%d of %d shards failed)", resp.Shards.Failed, resp.Shards.Total)
+}
+
+// indexBody reads the body of a request from --file, or returns an empty one.
+func indexBody(cmd *cobra.Command) (map[string]interface{}, error) {
+	file, err := cmd.Flags().GetString("file")
+	if err != nil || file == "" {
+		return map[string]interface{}{}, err
+	}
+	return readJSON(file)
+}
+
+// setting sets a flat setting in the settings of a create index body.
+func setting(body map[string]interface{}, name string, value interface{}) {
+	settings, ok := body["settings"].(map[string]interface{})
+	if !ok {
+		settings = map[string]interface{}{}
+		body["settings"] = settings
+	}
+	settings[name] = value
+}
+
+// printRequest prints the request a --dry-run would send.
+func printRequest(method string, path string, body interface{}) {
+	fmt.Printf("%s %s\n", method, path)
+	if body != nil {
+		data, _ := json.MarshalIndent(body, "", "  ")
+		fmt.Printf("%s\n", data)
+	}
+}
+
+var indexCreateCmd = &cobra.Command{
+	Use:   "create <index>",
+	Short: "Create an index",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		shards, err := cmd.Flags().GetInt("shards")
+		check(err)
+		replicas, err := cmd.Flags().GetInt("replicas")
+		check(err)
+		body, err := indexBody(cmd)
+		check(err)
+		if shards > 0 {
+			setting(body, "index.number_of_shards", shards)
+		}
+		if replicas >= 0 {
+			setting(body, "index.number_of_replicas", replicas)
+		}
+		if confirmFlags.dryRun {
+			printRequest(goreq.PUT, args[0], body)
+			return
+		}
+		client, err := newClient(cluster)
+		check(err)
+		_, err = client.Request(goreq.PUT, args[0], body)
+		check(err)
+		fmt.Printf("created %s\n", args[0])
+	},
+}
+
+// resizeCommand returns the shrink, split or clone command.
+func resizeCommand(resize string) *cobra.Command {
+	return &cobra.Command{
+		Use:   resize + " <source> <target>",
+		Short: strings.Title(resize) + " an index into a new index",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			cluster := cmd.Flag("cluster").Value.String()
+			blockWrite, err := cmd.Flags().GetBool("block-write")
+			check(err)
+			body, err := indexBody(cmd)
+			check(err)
+			if resize != "clone" {
+				shards, err := cmd.Flags().GetInt("shards")
+				check(err)
+				if shards <= 0 {
+					check(errors.New("--shards is required"))
+				}
+				setting(body, "index.number_of_shards", shards)
+			}
+			if blockWrite {
+				// the target copies the settings of the source
+				setting(body, "index.blocks.write", nil)
+			}
+			if resize == "shrink" {
+				setting(body, "index.routing.allocation.require._name", nil)
+			}
+
+			source, target := args[0], args[1]
+			block := map[string]interface{}{"index.blocks.write": true}
+			path := source + "/_" + resize + "/" + target
+			if confirmFlags.dryRun {
+				if blockWrite {
+					printRequest(goreq.PUT, source+"/_settings", block)
+				}
+				printRequest(goreq.POST, path, body)
+				return
+			}
+			client, err := newClient(cluster)
+			check(err)
+			if blockWrite {
+				_, err = client.Request(goreq.PUT, source+"/_settings", block)
+				check(err)
+			}
+			_, err = client.Request(goreq.POST, path, body)
+			check(err)
+			fmt.Printf("created %s from %s\n", target, source)
+		},
+	}
+}
+
+var indexRolloverCmd = &cobra.Command{
+	Use:   "rollover <alias> [new index]",
+	Short: "Roll an alias or data stream over to a new index",
+	Long: `Roll an alias or data stream over to a new index, when any of the conditions
+is met or unconditionally without conditions. --dry-run checks the conditions
+without rolling over.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		body, err := indexBody(cmd)
+		check(err)
+		conditions, ok := body["conditions"].(map[string]interface{})
+		if !ok {
+			conditions = map[string]interface{}{}
+		}
+		for _, name := range []string{"max-age", "max-size", "max-primary-shard-size"} {
+			if v, _ := cmd.Flags().GetString(name); v != "" {
+				conditions[strings.Replace(name, "-", "_", -1)] = v
+			}
+		}
+		if docs, _ := cmd.Flags().GetInt64("max-docs"); docs > 0 {
+			conditions["max_docs"] = docs
+		}
+		if len(conditions) > 0 {
+			body["conditions"] = conditions
+		}
+
+		path := strings.Join(args, "/_rollover/")
+		if len(args) == 1 {
+			path += "/_rollover"
+		}
+		if confirmFlags.dryRun {
+			path += "?dry_run=true"
+		}
+		client, err := newClient(cluster)
+		check(err)
+		data, err := client.Request(goreq.POST, path, body)
+		check(err)
+		var resp struct {
+			OldIndex   string          `json:"old_index"`
+			NewIndex   string          `json:"new_index"`
+			RolledOver bool            `json:"rolled_over"`
+			DryRun     bool            `json:"dry_run"`
+			Conditions map[string]bool `json:"conditions"`
+		}
+		check(json.Unmarshal(data, &resp))
+		t := &Table{Columns: []string{"old_index", "new_index", "rolled_over", "dry_run", "conditions"}}
+		t.Rows = append(t.Rows, []interface{}{resp.OldIndex, resp.NewIndex, resp.RolledOver, resp.DryRun, resp.Conditions})
+		check(t.Write(os.Stdout, outputFormat))
+	},
+}
@@ -34,9 +34,7 @@ to quickly create a Cobra application.`,
 		cluster := cmd.Flag("cluster").Value.String()
 
 		index, err := cmd.Flags().GetString("index")
-		if err != nil {
-			panic(err)
-		}
+		check(err)
 		if len(strings.Trim(index, "")) != 0 {
 			handleCatCommand(cluster, "indices"+"/"+index)
 			return
@@ -47,7 +45,7 @@ to quickly create a Cobra application.`,
 
 func init() {
 	EsCmd.AddCommand(indicesCmd)
+	addCatFlags(indicesCmd)
 
-	indicesCmd.Flags().StringP("cluster", "c", "localhost:9200", "es cluster")
 	indicesCmd.Flags().StringP("index", "i", "", "index pattern")
 }
@@ -0,0 +1,493 @@
+/*
+Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package es
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+)
+
+// loadCmd represents the es command
+var loadCmd = &cobra.Command{
+	Use:   "load <index>",
+	Short: "Import documents from NDJSON or CSV into an index",
+	Long: `Import documents from NDJSON or CSV with the bulk API, e.g.
+
+  hebe es load logs-copy -f logs.ndjson.gz --workers 4
+  hebe es load users -f users.csv --id-field email --op create --dead-letter failed.ndjson
+  cat docs.ndjson | hebe es load docs
+
+NDJSON lines are either documents, or the _index, _id and _source of documents as
+written by dump. CSV files start with a header of field names; an _id column sets the
+document ids, and values looking like JSON objects or arrays are decoded. The format
+defaults to the extension of --file, and gzipped input is decompressed.
+
+Bulk requests are cut at --batch-docs documents or --batch-size bytes, whichever
+comes first. Documents rejected with 429 Too Many Requests are retried with an
+exponential backoff; the other failures are written to --dead-letter, as NDJSON
+which load reads back, and the command exits with 1.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		file, err := cmd.Flags().GetString("file")
+		check(err)
+		format, err := cmd.Flags().GetString("format")
+		check(err)
+		idField, err := cmd.Flags().GetString("id-field")
+		check(err)
+		op, err := cmd.Flags().GetString("op")
+		check(err)
+		batchDocs, err := cmd.Flags().GetInt("batch-docs")
+		check(err)
+		batchSize, err := cmd.Flags().GetString("batch-size")
+		check(err)
+		workers, err := cmd.Flags().GetInt("workers")
+		check(err)
+		retries, err := cmd.Flags().GetInt("retries")
+		check(err)
+		deadLetter, err := cmd.Flags().GetString("dead-letter")
+		check(err)
+
+		if op != "index" && op != "create" {
+			check(fmt.Errorf("invalid --op %q, expected index or create", op))
+		}
+		batchBytes, err := parseByteSize(batchSize)
+		check(err)
+		if format == "" {
+			format = "ndjson"
+			if filepath.Ext(strings.TrimSuffix(file, ".gz")) == ".csv" {
+				format = "csv"
+			}
+		}
+		in, err := openInput(file)
+		check(err)
+		defer in.Close()
+		r, err := newDocReader(in, format, idField)
+		check(err)
+
+		client, err := newClient(cluster)
+		check(err)
+		l := &loader{client: client, index: args[0], op: op, retries: retries, done: make(chan struct{})}
+		l.progress.total = -1
+		if deadLetter != "" {
+			name, err := homedir.Expand(deadLetter)
+			check(err)
+			f, err := os.Create(name)
+			check(err)
+			defer f.Close()
+			l.deadLetter = json.NewEncoder(f)
+		}
+
+		l.progress.start(2 * time.Second)
+		err = l.run(r, workers, batchDocs, batchBytes)
+		l.progress.stop()
+		check(err)
+		if l.failed > 0 {
+			fmt.Fprintf(os.Stderr, "%d documents failed", l.failed)
+			if deadLetter != "" {
+				fmt.Fprintf(os.Stderr, ", see %s", deadLetter)
+			} else {
+				fmt.Fprint(os.Stderr, ", keep them with --dead-letter")
+			}
+			fmt.Fprintln(os.Stderr)
+			os.Exit(exitError)
+		}
+	},
+}
+
+func init() {
+	EsCmd.AddCommand(loadCmd)
+
+	loadCmd.Flags().StringP("file", "f", "-", "file to read, - for stdin")
+	loadCmd.Flags().String("format", "", "input format: ndjson or csv, by default from the --file extension")
+	loadCmd.Flags().String("id-field", "", "field holding the document ids")
+	loadCmd.Flags().String("op", "index", "bulk operation: index, replacing existing documents, or create")
+	loadCmd.Flags().Int("batch-docs", 1000, "maximum number of documents per bulk request")
+	loadCmd.Flags().String("batch-size", "5mb", "maximum size of a bulk request, e.g. 512kb, 10mb")
+	loadCmd.Flags().Int("workers", 2, "number of concurrent bulk requests")
+	loadCmd.Flags().Int("retries", 5, "number of retries of documents rejected with 429")
+	loadCmd.Flags().String("dead-letter", "", "NDJSON file to write failed documents to")
+}
+
+// parseByteSize parses a size such as 512kb or 10mb.
+func parseByteSize(s string) (int, error) {
+	units := []struct {
+		suffix string
+		size   int
+	}{{"pb", 1 << 50}, {"tb", 1 << 40}, {"gb", 1 << 30}, {"mb", 1 << 20}, {"kb", 1 << 10}, {"b", 1}}
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			n, err := strconv.ParseFloat(strings.TrimSuffix(s, u.suffix), 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid size %q", s)
+			}
+			return int(n * float64(u.size)), nil
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n, nil
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	f io.Closer
+}
+
+func (g *gzipFile) Close() error {
+	g.Reader.Close()
+	return g.f.Close()
+}
+
+// openInput opens a file, or stdin for -, decompressing it when it is gzipped.
+func openInput(name string) (io.ReadCloser, error) {
+	f := os.Stdin
+	if name != "-" {
+		var err error
+		if name, err = homedir.Expand(name); err != nil {
+			return nil, err
+		}
+		if f, err = os.Open(name); err != nil {
+			return nil, err
+		}
+	}
+	r := bufio.NewReader(f)
+	if magic, err := r.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		z, err := gzip.NewReader(r)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &gzipFile{z, f}, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{r, f}, nil
+}
+
+// bulkDoc is a document to index, with an empty id to have one generated.
+type bulkDoc struct {
+	id     string
+	source json.RawMessage
+}
+
+// docReader reads documents in one of the load formats, returning io.EOF after the last one.
+type docReader interface {
+	read() (*bulkDoc, error)
+}
+
+func newDocReader(r io.Reader, format string, idField string) (docReader, error) {
+	switch format {
+	case "ndjson":
+		return &ndjsonReader{r: bufio.NewReader(r), idField: idField}, nil
+	case "csv":
+		c := csv.NewReader(r)
+		c.ReuseRecord = true
+		header, err := c.Read()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CSV header: %v", err)
+		}
+		return &csvReader{r: c, header: append([]string(nil), header...), idField: idField}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q, expected ndjson or csv", format)
+}
+
+type ndjsonReader struct {
+	r       *bufio.Reader
+	idField string
+	line    int
+}
+
+func (r *ndjsonReader) read() (*bulkDoc, error) {
+	for {
+		line, err := r.r.ReadBytes('\n')
+		r.line++
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		doc, err := r.parse(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", r.line, err)
+		}
+		return doc, nil
+	}
+}
+
+func (r *ndjsonReader) parse(line []byte) (*bulkDoc, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return nil, err
+	}
+	doc := &bulkDoc{source: line}
+	if source, ok := fields["_source"]; ok {
+		doc.source = source
+		if id, ok := fields["_id"]; ok {
+			if err := json.Unmarshal(id, &doc.id); err != nil {
+				return nil, fmt.Errorf("invalid _id: %v", err)
+			}
+		}
+	}
+	if r.idField != "" {
+		var source map[string]interface{}
+		d := json.NewDecoder(bytes.NewReader(doc.source))
+		d.UseNumber()
+		if err := d.Decode(&source); err != nil {
+			return nil, err
+		}
+		doc.id = cell(fieldValue(source, r.idField))
+	}
+	return doc, nil
+}
+
+type csvReader struct {
+	r       *csv.Reader
+	header  []string
+	idField string
+}
+
+func (r *csvReader) read() (*bulkDoc, error) {
+	record, err := r.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	doc := &bulkDoc{}
+	source := map[string]interface{}{}
+	for i, field := range r.header {
+		if i >= len(record) || record[i] == "" {
+			continue
+		}
+		v := record[i]
+		if field == "_id" || field == r.idField {
+			doc.id = v
+		}
+		if metaFields[field] {
+			continue
+		}
+		if (v[0] == '{' || v[0] == '[') && json.Valid([]byte(v)) {
+			source[field] = json.RawMessage(v)
+		} else {
+			source[field] = v
+		}
+	}
+	if doc.source, err = json.Marshal(source); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// maxBulkBackoff caps the delay between two retries of rejected documents.
+const maxBulkBackoff = 30 * time.Second
+
+// loader sends documents to an index with bulk requests.
+type loader struct {
+	client  *Client
+	index   string
+	op      string
+	retries int
+
+	progress   progress
+	mu         sync.Mutex
+	deadLetter *json.Encoder
+	failed     int64
+	err        error
+	done       chan struct{}
+}
+
+// fail records the first error stopping the load.
+func (l *loader) fail(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err == nil {
+		l.err = err
+		close(l.done)
+	}
+}
+
+// run reads the documents into batches, sent by workers concurrent goroutines.
+func (l *loader) run(r docReader, workers int, batchDocs int, batchBytes int) error {
+	if workers < 1 {
+		workers = 1
+	}
+	batches := make(chan []*bulkDoc, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				if err := l.send(batch); err != nil {
+					l.fail(err)
+				}
+			}
+		}()
+	}
+
+	var batch []*bulkDoc
+	size := 0
+	flush := func() bool {
+		if len(batch) == 0 {
+			return true
+		}
+		select {
+		case batches <- batch:
+			batch, size = nil, 0
+			return true
+		case <-l.done:
+			return false
+		}
+	}
+	for {
+		doc, err := r.read()
+		if err == io.EOF {
+			flush()
+			break
+		}
+		if err != nil {
+			l.fail(err)
+			break
+		}
+		// the action line takes less than 100 bytes
+		if len(batch) > 0 && size+len(doc.source)+100 > batchBytes {
+			if !flush() {
+				break
+			}
+		}
+		batch = append(batch, doc)
+		size += len(doc.source) + 100
+		if len(batch) >= batchDocs && !flush() {
+			break
+		}
+	}
+	close(batches)
+	wg.Wait()
+	return l.err
+}
+
+type bulkResult struct {
+	ID     string          `json:"_id"`
+	Status int             `json:"status"`
+	Error  json.RawMessage `json:"error"`
+}
+
+// send indexes docs, retrying those rejected with 429 and writing the other failures to the dead letter file.
+func (l *loader) send(docs []*bulkDoc) error {
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		select {
+		case <-l.done:
+			return nil
+		default:
+		}
+		var body bytes.Buffer
+		for _, doc := range docs {
+			action := map[string]string{}
+			if doc.id != "" {
+				action["_id"] = doc.id
+			}
+			line, _ := json.Marshal(map[string]interface{}{l.op: action})
+			body.Write(line)
+			body.WriteByte('\n')
+			body.Write(doc.source)
+			body.WriteByte('\n')
+		}
+		data, err := l.client.Bulk(l.index+"/_bulk", body.String())
+		if e, ok := err.(*ResponseError); ok && e.Status == 429 && attempt < l.retries {
+			time.Sleep(backoff)
+			backoff = nextBackoff(backoff)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		var resp struct {
+			Items []map[string]bulkResult `json:"items"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return fmt.Errorf("unexpected bulk response: %v", err)
+		}
+		if len(resp.Items) != len(docs) {
+			return fmt.Errorf("unexpected bulk response: %d items for %d documents", len(resp.Items), len(docs))
+		}
+		var rejected []*bulkDoc
+		for i, item := range resp.Items {
+			result := item[l.op]
+			switch {
+			case result.Status == 429 && attempt < l.retries:
+				rejected = append(rejected, docs[i])
+			case result.Status >= 300:
+				if err := l.reject(docs[i], result); err != nil {
+					return err
+				}
+			default:
+				l.progress.add(1)
+			}
+		}
+		if len(rejected) == 0 {
+			return nil
+		}
+		docs = rejected
+		time.Sleep(backoff)
+		backoff = nextBackoff(backoff)
+	}
+}
+
+func nextBackoff(d time.Duration) time.Duration {
+	if d *= 2; d > maxBulkBackoff {
+		return maxBulkBackoff
+	}
+	return d
+}
+
+// reject counts a failed document and writes it to the dead letter file.
+func (l *loader) reject(doc *bulkDoc, result bulkResult) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.failed++
+	if l.deadLetter == nil {
+		return nil
+	}
+	return l.deadLetter.Encode(struct {
+		ID     string          `json:"_id,omitempty"`
+		Source json.RawMessage `json:"_source"`
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error,omitempty"`
+	}{doc.id, doc.source, result.Status, result.Error})
+}
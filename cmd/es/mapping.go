@@ -0,0 +1,225 @@
+/*
+Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package es
+
+import (
+	"encoding/json"
+	"fmt"
+	"hebe/langs/goreq"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// mappingCmd represents the es command
+var mappingCmd = &cobra.Command{
+	Use:   "mapping <index>",
+	Short: "Display the mappings of indices",
+	Long: `Display the mapped fields of the indices matching a pattern, one row per field with its
+type and other parameters, multi-fields and object properties being named by their
+dotted path. --tree shows the fields nested instead, e.g.
+
+  hebe es mapping logs-2026.10.16
+  hebe es mapping 'logs-*' --tree`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		tree, err := cmd.Flags().GetBool("tree")
+		check(err)
+		client, err := newClient(cluster)
+		check(err)
+		indices, err := getIndices(client, args[0], false)
+		check(err)
+		names := indexNames(indices)
+		if tree {
+			for _, name := range names {
+				fmt.Println(name)
+				writeMappingTree(os.Stdout, indices[name].mappings(), "  ")
+			}
+			return
+		}
+		t := &Table{Columns: []string{"index", "field", "type", "params"}}
+		for _, name := range names {
+			for _, f := range flattenMapping(indices[name].mappings()) {
+				t.Rows = append(t.Rows, []interface{}{name, f.field, f.typ, f.params})
+			}
+		}
+		check(t.Write(os.Stdout, outputFormat))
+	},
+}
+
+func init() {
+	EsCmd.AddCommand(mappingCmd)
+	mappingCmd.Flags().Bool("tree", false, "show the fields nested instead of by dotted path")
+}
+
+// indexDefinition is an index as returned by GET <index>, with flat settings.
+type indexDefinition struct {
+	Aliases  map[string]interface{} `json:"aliases"`
+	Mappings map[string]interface{} `json:"mappings"`
+	Settings map[string]interface{} `json:"settings"`
+	Defaults map[string]interface{} `json:"defaults"`
+}
+
+// getIndices returns the definitions of the indices matching pattern, with the default settings if asked.
+func getIndices(client *Client, pattern string, defaults bool) (map[string]indexDefinition, error) {
+	query := url.Values{"flat_settings": {"true"}}
+	if defaults {
+		query.Set("include_defaults", "true")
+	}
+	data, err := client.Request(goreq.GET, pattern+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	var indices map[string]indexDefinition
+	if err := json.Unmarshal(data, &indices); err != nil {
+		return nil, err
+	}
+	if len(indices) == 0 {
+		return nil, fmt.Errorf("no index matches %s", pattern)
+	}
+	return indices, nil
+}
+
+// indexNames returns the sorted names of indices.
+func indexNames(indices map[string]indexDefinition) []string {
+	names := make([]string, 0, len(indices))
+	for name := range indices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (d indexDefinition) mappings() map[string]interface{} {
+	return typelessMappings(d.Mappings)
+}
+
+// typelessMappings removes the mapping type of versions before 7.0, e.g. {"_doc": {"properties": ...}}.
+func typelessMappings(m map[string]interface{}) map[string]interface{} {
+	if _, ok := m["properties"]; ok || len(m) != 1 {
+		return m
+	}
+	for _, v := range m {
+		if typed, ok := v.(map[string]interface{}); ok {
+			if _, ok := typed["properties"]; ok {
+				return typed
+			}
+		}
+	}
+	return m
+}
+
+// mappingField is a mapped field with its parameters, or a parameter of the root such as dynamic with its value.
+type mappingField struct {
+	field  string
+	typ    string
+	params interface{}
+}
+
+// splitField separates the type of a field definition from its other parameters, nil when none.
+func splitField(def map[string]interface{}) (string, interface{}) {
+	typ := "object"
+	params := map[string]interface{}{}
+	for k, v := range def {
+		switch k {
+		case "type":
+			typ = cell(v)
+		case "properties", "fields":
+		default:
+			params[k] = v
+		}
+	}
+	if len(params) == 0 {
+		return typ, nil
+	}
+	return typ, params
+}
+
+// flattenMapping lists the fields of a mapping by dotted path, objects and multi-fields included,
+// after the root parameters other than properties.
+func flattenMapping(mapping map[string]interface{}) []mappingField {
+	var fields []mappingField
+	for _, k := range sortedKeys(mapping) {
+		if k != "properties" {
+			fields = append(fields, mappingField{field: k, params: mapping[k]})
+		}
+	}
+	var walk func(prefix string, properties map[string]interface{})
+	walk = func(prefix string, properties map[string]interface{}) {
+		for _, name := range sortedKeys(properties) {
+			def, _ := properties[name].(map[string]interface{})
+			f := mappingField{field: prefix + name}
+			f.typ, f.params = splitField(def)
+			fields = append(fields, f)
+			for _, k := range []string{"properties", "fields"} {
+				if sub, ok := def[k].(map[string]interface{}); ok {
+					walk(f.field+".", sub)
+				}
+			}
+		}
+	}
+	properties, _ := mapping["properties"].(map[string]interface{})
+	walk("", properties)
+	return fields
+}
+
+// writeMappingTree prints the fields of a mapping nested by indent.
+func writeMappingTree(w io.Writer, mapping map[string]interface{}, indent string) {
+	var walk func(depth string, properties map[string]interface{})
+	walk = func(depth string, properties map[string]interface{}) {
+		for _, name := range sortedKeys(properties) {
+			def, _ := properties[name].(map[string]interface{})
+			typ, params := splitField(def)
+			line := depth + name + " " + typ
+			if params != nil {
+				data, _ := json.Marshal(params)
+				line += " " + string(data)
+			}
+			fmt.Fprintln(w, line)
+			for _, k := range []string{"properties", "fields"} {
+				if sub, ok := def[k].(map[string]interface{}); ok {
+					walk(depth+indent, sub)
+				}
+			}
+		}
+	}
+	properties, _ := mapping["properties"].(map[string]interface{})
+	walk(indent, properties)
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// definition renders a mapped field for comparisons, e.g. keyword {"ignore_above":256}.
+func (f mappingField) definition() string {
+	if f.params == nil {
+		return f.typ
+	}
+	data, _ := json.Marshal(f.params)
+	return strings.TrimSpace(f.typ + " " + string(data))
+}
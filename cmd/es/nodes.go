@@ -32,9 +32,7 @@ to quickly create a Cobra application.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		cluster := cmd.Flag("cluster").Value.String()
 		attrs, err := cmd.Flags().GetBool("attrs")
-		if err != nil {
-			panic(err)
-		}
+		check(err)
 		if attrs {
 			handleCatCommand(cluster, "nodeattrs")
 			return
@@ -45,7 +43,7 @@ to quickly create a Cobra application.`,
 
 func init() {
 	EsCmd.AddCommand(nodesCmd)
+	addCatFlags(nodesCmd)
 
-	nodesCmd.Flags().StringP("cluster", "c", "localhost:9200", "es cluster")
 	nodesCmd.Flags().BoolP("attrs", "a", false, "display node attributes")
 }
@@ -37,6 +37,5 @@ to quickly create a Cobra application.`,
 
 func init() {
 	EsCmd.AddCommand(pluginsCmd)
-
-	pluginsCmd.Flags().StringP("cluster", "c", "localhost:9200", "es cluster")
+	addCatFlags(pluginsCmd)
 }
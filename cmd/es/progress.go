@@ -0,0 +1,82 @@
+package es
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// progress reports the number of documents processed by a long running command to stderr,
+// in place on a terminal.
+type progress struct {
+	total   int64 // -1 when unknown
+	count   int64
+	started time.Time
+	stopped chan struct{}
+	done    chan struct{}
+}
+
+// start reports the progress every interval until stop.
+func (p *progress) start(interval time.Duration) {
+	p.started = time.Now()
+	p.stopped = make(chan struct{})
+	p.done = make(chan struct{})
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.report(false)
+			case <-p.stopped:
+				p.report(true)
+				return
+			}
+		}
+	}()
+}
+
+func (p *progress) add(n int64) {
+	atomic.AddInt64(&p.count, n)
+}
+
+func (p *progress) setTotal(n int64) {
+	atomic.StoreInt64(&p.total, n)
+}
+
+func (p *progress) set(n int64) {
+	atomic.StoreInt64(&p.count, n)
+}
+
+func (p *progress) value() int64 {
+	return atomic.LoadInt64(&p.count)
+}
+
+// stop prints the final count.
+func (p *progress) stop() {
+	close(p.stopped)
+	<-p.done
+}
+
+func (p *progress) report(last bool) {
+	count, total := p.value(), atomic.LoadInt64(&p.total)
+	elapsed := time.Since(p.started)
+	line := fmt.Sprintf("%d", count)
+	if total >= 0 {
+		line += fmt.Sprintf(" of %d", total)
+	}
+	line += fmt.Sprintf(" documents in %s", elapsed.Round(time.Second))
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		line += fmt.Sprintf(", %.0f/s", float64(count)/seconds)
+	}
+	switch {
+	case isTerminal(os.Stderr) && last:
+		fmt.Fprintf(os.Stderr, "\r%s\x1b[K\n", line)
+	case isTerminal(os.Stderr):
+		fmt.Fprintf(os.Stderr, "\r%s\x1b[K", line)
+	default:
+		fmt.Fprintln(os.Stderr, line)
+	}
+}
@@ -0,0 +1,235 @@
+package es
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// label is a Prometheus label of a sample.
+type label struct {
+	name  string
+	value string
+}
+
+// metrics collects samples in the Prometheus text exposition format, keeping the
+// samples of each metric together as the format requires.
+type metrics struct {
+	names   []string
+	help    map[string]string
+	samples map[string][]string
+}
+
+func newMetrics() *metrics {
+	return &metrics{help: map[string]string{}, samples: map[string][]string{}}
+}
+
+// metricLabels are the labels of the metrics of a cluster, its name and the labels of its profile.
+func (c *Cluster) metricLabels() []label {
+	labels := []label{{"cluster", c.Name}}
+	names := make([]string, 0, len(c.Labels))
+	for name := range c.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if n := metricName(name); n != "cluster" {
+			labels = append(labels, label{n, c.Labels[name]})
+		}
+	}
+	return labels
+}
+
+var invalidMetricChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// metricName replaces the characters Prometheus does not allow in names, e.g. the dots of _cat columns.
+func metricName(s string) string {
+	return invalidMetricChars.ReplaceAllString(s, "_")
+}
+
+func (m *metrics) add(name string, help string, labels []label, value float64) {
+	if _, ok := m.help[name]; !ok {
+		m.names = append(m.names, name)
+		m.help[name] = help
+	}
+	var b strings.Builder
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(l.name + `="` + escapeLabel(l.value) + `"`)
+		}
+		b.WriteByte('}')
+	}
+	b.WriteString(" " + strconv.FormatFloat(value, 'g', -1, 64))
+	m.samples[name] = append(m.samples[name], b.String())
+}
+
+// merge appends the samples of other, e.g. those of another cluster.
+func (m *metrics) merge(other *metrics) {
+	for _, name := range other.names {
+		if _, ok := m.help[name]; !ok {
+			m.names = append(m.names, name)
+			m.help[name] = other.help[name]
+		}
+		m.samples[name] = append(m.samples[name], other.samples[name]...)
+	}
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// healthCodes are the values of the green, yellow and red columns.
+var healthCodes = map[string]float64{"green": 0, "yellow": 1, "red": 2}
+
+// catLabelColumns are the columns of the _cat APIs naming their rows, which label the samples of
+// the other columns. They are told by name rather than by the cells of each scrape, so that an
+// empty or - cell never turns a label into a metric, or the other way round, between scrapes.
+var catLabelColumns = map[string]bool{
+	"alias":             true,
+	"cluster":           true,
+	"component":         true,
+	"filter":            true,
+	"host":              true,
+	"id":                true,
+	"index":             true,
+	"ip":                true,
+	"is_write_index":    true,
+	"master":            true,
+	"name":              true,
+	"node":              true,
+	"node.role":         true,
+	"node_name":         true,
+	"policy":            true,
+	"prirep":            true,
+	"priority":          true,
+	"repository":        true,
+	"routing.index":     true,
+	"routing.search":    true,
+	"segment":           true,
+	"shard":             true,
+	"snapshot":          true,
+	"source":            true,
+	"source_node":       true,
+	"stage":             true,
+	"state":             true,
+	"target_node":       true,
+	"type":              true,
+	"unassigned.reason": true,
+	"uuid":              true,
+	"version":           true,
+}
+
+// labelColumn tells whether a column of a _cat API labels the samples. status is the health of
+// _cat/health, but whether indices are open or closed elsewhere.
+func labelColumn(api string, column string) bool {
+	if column == "status" {
+		return !healthColumn(api, column)
+	}
+	return catLabelColumns[column]
+}
+
+// healthColumn tells whether a column of a _cat API is a health, green, yellow or red.
+func healthColumn(api string, column string) bool {
+	return column == "health" || column == "status" && strings.SplitN(api, "/", 2)[0] == "health"
+}
+
+// addTable adds the rows of a _cat table as samples of prefix_<column>, one metric for each column
+// but those naming the rows, see catLabelColumns, which label the samples. Health columns count
+// green 0, yellow 1 and red 2; the cells which are not numbers are skipped. The number of rows is
+// added as prefix_rows.
+func (m *metrics) addTable(prefix string, api string, labels []label, t *Table) {
+	taken := map[string]bool{}
+	for _, l := range labels {
+		taken[l.name] = true
+	}
+	var metricColumns, labelColumns []int
+	var labelNames []string
+	for j, column := range t.Columns {
+		if !labelColumn(api, column) {
+			metricColumns = append(metricColumns, j)
+			continue
+		}
+		name := metricName(column)
+		if taken[name] {
+			name = "es_" + name
+		}
+		labelColumns = append(labelColumns, j)
+		labelNames = append(labelNames, name)
+	}
+	for _, j := range metricColumns {
+		name := prefix + "_" + metricName(t.Columns[j])
+		help := fmt.Sprintf("%s of _cat/%s", t.Columns[j], api)
+		if healthColumn(api, t.Columns[j]) {
+			help += ", 0 green, 1 yellow, 2 red"
+		}
+		for _, row := range t.Rows {
+			s := cell(row[j])
+			value, ok := healthCodes[s]
+			if !ok {
+				var err error
+				if value, err = strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64); err != nil {
+					continue
+				}
+			}
+			rowLabels := append([]label(nil), labels...)
+			for i, k := range labelColumns {
+				if v := cell(row[k]); v != "" {
+					rowLabels = append(rowLabels, label{labelNames[i], v})
+				}
+			}
+			m.add(name, help, rowLabels, value)
+		}
+	}
+	m.add(prefix+"_rows", "rows of _cat/"+api, labels, float64(len(t.Rows)))
+}
+
+// numeric tells if the non empty cells of column j are all numbers, percentages included.
+func (t *Table) numeric(j int) bool {
+	return t.every(j, func(s string) bool {
+		_, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		return err == nil
+	})
+}
+
+// health tells if the non empty cells of column j are all green, yellow or red.
+func (t *Table) health(j int) bool {
+	return t.every(j, func(s string) bool {
+		_, ok := healthCodes[s]
+		return ok
+	})
+}
+
+func (t *Table) every(j int, f func(s string) bool) bool {
+	found := false
+	for _, row := range t.Rows {
+		s := cell(row[j])
+		if s == "" {
+			continue
+		}
+		if !f(s) {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+func (m *metrics) write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, name := range m.names {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s gauge\n", name, m.help[name], name)
+		for _, s := range m.samples[name] {
+			fmt.Fprintln(bw, s)
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,148 @@
+package es
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMetricsText(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		api    string
+		labels []label
+		table  *Table
+		want   string
+	}{
+		{
+			name:   "health",
+			prefix: "hebe_es_health",
+			api:    "health",
+			labels: []label{{"cluster", "prod"}},
+			table: &Table{
+				Columns: []string{"cluster", "status", "unassign", "active_shards_percent"},
+				Rows:    [][]interface{}{{"logs", "yellow", "2", "97.5%"}},
+			},
+			want: `# HELP hebe_es_health_status status of _cat/health, 0 green, 1 yellow, 2 red
+# TYPE hebe_es_health_status gauge
+hebe_es_health_status{cluster="prod",es_cluster="logs"} 1
+# HELP hebe_es_health_unassign unassign of _cat/health
+# TYPE hebe_es_health_unassign gauge
+hebe_es_health_unassign{cluster="prod",es_cluster="logs"} 2
+# HELP hebe_es_health_active_shards_percent active_shards_percent of _cat/health
+# TYPE hebe_es_health_active_shards_percent gauge
+hebe_es_health_active_shards_percent{cluster="prod",es_cluster="logs"} 97.5
+# HELP hebe_es_health_rows rows of _cat/health
+# TYPE hebe_es_health_rows gauge
+hebe_es_health_rows{cluster="prod"} 1
+`,
+		},
+		{
+			name:   "indices",
+			prefix: "hebe_es_indices",
+			api:    "indices/logs-*",
+			labels: []label{{"cluster", "prod"}, {"env", "eu"}},
+			table: &Table{
+				Columns: []string{"index", "health", "status", "docs.count"},
+				Rows: [][]interface{}{
+					{"logs-1", "green", "open", "10"},
+					{"logs-2", nil, "close", nil},
+				},
+			},
+			want: `# HELP hebe_es_indices_health health of _cat/indices/logs-*, 0 green, 1 yellow, 2 red
+# TYPE hebe_es_indices_health gauge
+hebe_es_indices_health{cluster="prod",env="eu",index="logs-1",status="open"} 0
+# HELP hebe_es_indices_docs_count docs.count of _cat/indices/logs-*
+# TYPE hebe_es_indices_docs_count gauge
+hebe_es_indices_docs_count{cluster="prod",env="eu",index="logs-1",status="open"} 10
+# HELP hebe_es_indices_rows rows of _cat/indices/logs-*
+# TYPE hebe_es_indices_rows gauge
+hebe_es_indices_rows{cluster="prod",env="eu"} 2
+`,
+		},
+		{
+			name:   "labels whatever their cells",
+			prefix: "hebe_es_allocation",
+			api:    "allocation",
+			labels: []label{{"cluster", "prod"}},
+			table: &Table{
+				Columns: []string{"node", "shards", "disk.percent"},
+				Rows: [][]interface{}{
+					{"42", "5", "-"},
+					{"", "2", ""},
+				},
+			},
+			want: `# HELP hebe_es_allocation_shards shards of _cat/allocation
+# TYPE hebe_es_allocation_shards gauge
+hebe_es_allocation_shards{cluster="prod",node="42"} 5
+hebe_es_allocation_shards{cluster="prod"} 2
+# HELP hebe_es_allocation_rows rows of _cat/allocation
+# TYPE hebe_es_allocation_rows gauge
+hebe_es_allocation_rows{cluster="prod"} 2
+`,
+		},
+		{
+			name:   "escaped labels",
+			prefix: "hebe_es_thread_pool",
+			api:    "thread_pool",
+			labels: []label{{"cluster", "prod"}},
+			table: &Table{
+				Columns: []string{"node_name", "name", "queue"},
+				Rows:    [][]interface{}{{`n"1\`, "write", "3"}},
+			},
+			want: `# HELP hebe_es_thread_pool_queue queue of _cat/thread_pool
+# TYPE hebe_es_thread_pool_queue gauge
+hebe_es_thread_pool_queue{cluster="prod",node_name="n\"1\\",name="write"} 3
+# HELP hebe_es_thread_pool_rows rows of _cat/thread_pool
+# TYPE hebe_es_thread_pool_rows gauge
+hebe_es_thread_pool_rows{cluster="prod"} 1
+`,
+		},
+	}
+	for _, test := range tests {
+		m := newMetrics()
+		m.addTable(test.prefix, test.api, test.labels, test.table)
+		var b bytes.Buffer
+		if err := m.write(&b); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, b.String(), test.want)
+		}
+	}
+}
+
+func TestMetricsMerge(t *testing.T) {
+	a, b := newMetrics(), newMetrics()
+	a.add("hebe_es_up", "whether the last scrape of the cluster succeeded", []label{{"cluster", "a"}}, 1)
+	b.add("hebe_es_up", "whether the last scrape of the cluster succeeded", []label{{"cluster", "b"}}, 0)
+	b.add("hebe_es_scrape_duration_seconds", "duration of the last scrape of the cluster", []label{{"cluster", "b"}}, 0.25)
+	a.merge(b)
+	var out bytes.Buffer
+	a.write(&out)
+	want := `# HELP hebe_es_up whether the last scrape of the cluster succeeded
+# TYPE hebe_es_up gauge
+hebe_es_up{cluster="a"} 1
+hebe_es_up{cluster="b"} 0
+# HELP hebe_es_scrape_duration_seconds duration of the last scrape of the cluster
+# TYPE hebe_es_scrape_duration_seconds gauge
+hebe_es_scrape_duration_seconds{cluster="b"} 0.25
+`
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestMetricLabels(t *testing.T) {
+	c := &Cluster{Name: "prod", Labels: map[string]string{"team": "search", "data-center": "eu-1", "cluster": "ignored"}}
+	got := c.metricLabels()
+	want := []label{{"cluster", "prod"}, {"data_center", "eu-1"}, {"team", "search"}}
+	if len(got) != len(want) {
+		t.Fatalf("metricLabels() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("metricLabels()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,56 @@
+/*
+Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package es
+
+import (
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+// recoveryCmd represents the es command
+var recoveryCmd = &cobra.Command{
+	Use:   "recovery",
+	Short: "Display shard recoveries, ongoing and completed",
+	Long: `Display shard recoveries, ongoing and completed, such as replicas being
+rebuilt after a node restart or shards relocating. For example, to follow the
+recoveries of a rolling restart:
+
+  hebe es recovery --active --watch 2s`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		index, err := cmd.Flags().GetString("index")
+		check(err)
+		active, err := cmd.Flags().GetBool("active")
+		check(err)
+		api := "recovery"
+		if len(strings.Trim(index, "")) != 0 {
+			api += "/" + index
+		}
+		options := []string{"h=index,shard,time,type,stage,source_node,target_node,files_percent,bytes_percent,translog_ops_percent"}
+		if active {
+			options = append(options, "active_only=true")
+		}
+		handleCatCommand(cluster, api, options...)
+	},
+}
+
+func init() {
+	EsCmd.AddCommand(recoveryCmd)
+	addCatFlags(recoveryCmd)
+
+	recoveryCmd.Flags().StringP("index", "i", "", "index pattern")
+	recoveryCmd.Flags().BoolP("active", "a", false, "only display ongoing recoveries")
+}
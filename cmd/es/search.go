@@ -0,0 +1,411 @@
+/*
+Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package es
+
+import (
+	"encoding/json"
+	"fmt"
+	"hebe/langs/goreq"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+// searchCmd represents the es command
+var searchCmd = &cobra.Command{
+	Use:   "search <index>",
+	Short: "Search documents of an index",
+	Long: `Search documents with a Lucene query string and/or a query DSL body, e.g.
+
+  hebe es search logs-* -q 'status:500 AND host:web*' --fields @timestamp,host,message --sort @timestamp:desc
+  echo '{"query":{"term":{"user.id":"kimchy"}}}' | hebe es search users --body - -o ndjson
+
+More hits than --page-size are fetched page by page with search_after, within a
+point in time on Elasticsearch 7.10 and later, or with scroll on older versions
+and OpenSearch.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		fields, err := cmd.Flags().GetString("fields")
+		check(err)
+		sort, err := cmd.Flags().GetString("sort")
+		check(err)
+		size, err := cmd.Flags().GetInt("size")
+		check(err)
+		pageSize, err := cmd.Flags().GetInt("page-size")
+		check(err)
+		after, err := cmd.Flags().GetString("search-after")
+		check(err)
+		noPIT, err := cmd.Flags().GetBool("no-pit")
+		check(err)
+
+		client, err := newClient(cluster)
+		check(err)
+		body, err := queryBody()
+		check(err)
+		var columns []string
+		if fields != "" {
+			columns = strings.Split(fields, ",")
+			body["_source"] = columns
+		}
+		if sort != "" {
+			body["sort"] = parseSort(sort)
+		}
+		if after != "" {
+			var values []interface{}
+			d := json.NewDecoder(strings.NewReader(after))
+			d.UseNumber()
+			check(d.Decode(&values))
+			body["search_after"] = values
+		}
+
+		s, err := openSearcher(client, args[0], !noPIT && size > pageSize)
+		check(err)
+		defer s.close()
+		var hits []hit
+		var total string
+		err = s.pages(body, pageSize, size, func(resp *searchResponse) error {
+			if total == "" {
+				total = resp.total()
+			}
+			hits = append(hits, resp.Hits.Hits...)
+			return nil
+		})
+		check(err)
+		check(hitsTable(hits, columns).Write(os.Stdout, outputFormat))
+		if len(hits) > 0 {
+			fmt.Fprintf(os.Stderr, "%d of %s hits\n", len(hits), total)
+			if last := hits[len(hits)-1].Sort; len(last) > 0 && fmt.Sprint(len(hits)) != total {
+				next, _ := json.Marshal(last)
+				fmt.Fprintf(os.Stderr, "next page: --search-after '%s'\n", next)
+			}
+		}
+	},
+}
+
+func init() {
+	EsCmd.AddCommand(searchCmd)
+	addQueryFlags(searchCmd)
+
+	searchCmd.Flags().String("fields", "", "comma separated source fields to display")
+	searchCmd.Flags().String("sort", "", "comma separated fields to sort by, e.g. @timestamp:desc,_id")
+	searchCmd.Flags().Int("size", 10, "number of hits to return")
+	searchCmd.Flags().Int("page-size", 1000, "number of hits fetched per request")
+	searchCmd.Flags().String("search-after", "", "JSON array of sort values to continue after")
+	searchCmd.Flags().Bool("no-pit", false, "page without a point in time")
+}
+
+// queryFlags select documents for the commands reading them, see addQueryFlags.
+var queryFlags struct {
+	query string
+	body  string
+}
+
+func addQueryFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&queryFlags.query, "query", "q", "", "Lucene query string, e.g. 'status:500 AND host:web*'")
+	cmd.Flags().StringVar(&queryFlags.body, "body", "", "JSON request body with the query DSL, from a file or - for stdin")
+}
+
+// queryBody returns the --body request, adding --query to its query.
+func queryBody() (map[string]interface{}, error) {
+	body := map[string]interface{}{}
+	if queryFlags.body != "" {
+		var err error
+		if body, err = readJSON(queryFlags.body); err != nil {
+			return nil, err
+		}
+	}
+	if queryFlags.query != "" {
+		q := map[string]interface{}{"query_string": map[string]interface{}{"query": queryFlags.query}}
+		if query, ok := body["query"]; ok {
+			q = map[string]interface{}{"bool": map[string]interface{}{"must": []interface{}{query, q}}}
+		}
+		body["query"] = q
+	}
+	return body, nil
+}
+
+// parseSort converts `a:desc,b` to the sort of a search body.
+func parseSort(spec string) []interface{} {
+	var sort []interface{}
+	for _, field := range strings.Split(spec, ",") {
+		if i := strings.LastIndex(field, ":"); i > 0 {
+			sort = append(sort, map[string]interface{}{field[:i]: map[string]interface{}{"order": field[i+1:]}})
+		} else {
+			sort = append(sort, field)
+		}
+	}
+	return sort
+}
+
+// pitKeepAlive is how long a point in time is kept between two pages.
+const pitKeepAlive = "5m"
+
+type hit struct {
+	Index  string                 `json:"_index"`
+	ID     string                 `json:"_id"`
+	Score  interface{}            `json:"_score"`
+	Source map[string]interface{} `json:"_source"`
+	Sort   []interface{}          `json:"sort"`
+}
+
+type searchResponse struct {
+	PitID    string `json:"pit_id"`
+	ScrollID string `json:"_scroll_id"`
+	Hits     struct {
+		Total json.RawMessage `json:"total"`
+		Hits  []hit           `json:"hits"`
+	} `json:"hits"`
+}
+
+// total returns the hit count, prefixed with >= when it is a lower bound.
+func (r *searchResponse) total() string {
+	var total struct {
+		Value    int64  `json:"value"`
+		Relation string `json:"relation"`
+	}
+	if err := json.Unmarshal(r.Hits.Total, &total); err != nil {
+		// versions before 7.0 return a number
+		return string(r.Hits.Total)
+	}
+	if total.Relation == "gte" {
+		return fmt.Sprintf(">=%d", total.Value)
+	}
+	return fmt.Sprint(total.Value)
+}
+
+func decodeSearch(r io.Reader) (*searchResponse, error) {
+	var resp searchResponse
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	if err := d.Decode(&resp); err != nil {
+		return nil, fmt.Errorf("unexpected search response: %v", err)
+	}
+	return &resp, nil
+}
+
+// scrollKeepAlive is how long a scroll is kept between two pages.
+const scrollKeepAlive = "5m"
+
+// searcher pages through search hits with search_after, within a point in time when it has one,
+// or with scroll.
+type searcher struct {
+	client *Client
+	index  string
+	scroll bool
+	mu     sync.Mutex
+	pit    string
+}
+
+// openSearcher opens a point in time when pit is set, falling back to scroll on clusters without them.
+func openSearcher(client *Client, index string, pit bool) (*searcher, error) {
+	s := &searcher{client: client, index: index}
+	if !pit {
+		return s, nil
+	}
+	ok, err := supportsPIT(client)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		s.scroll = true
+		return s, nil
+	}
+	data, err := client.Request(goreq.POST, index+"/_pit?keep_alive="+pitKeepAlive, nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	s.pit = resp.ID
+	return s, nil
+}
+
+// supportsPIT tells whether the cluster has the _pit API, added in Elasticsearch 7.10; OpenSearch
+// has its own point in time API instead.
+func supportsPIT(client *Client) (bool, error) {
+	flavor, number, err := client.version()
+	if err != nil || flavor != "elasticsearch" {
+		return false, err
+	}
+	var major, minor int
+	fmt.Sscanf(number, "%d.%d", &major, &minor)
+	return major > 7 || major == 7 && minor >= 10, nil
+}
+
+func (s *searcher) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pit != "" {
+		s.client.Request(goreq.DELETE, "_pit", map[string]interface{}{"id": s.pit})
+	}
+}
+
+// pages runs the search body, passing each page of at most pageSize hits to fn,
+// until max hits were passed or, when max <= 0, the hits run out.
+func (s *searcher) pages(body map[string]interface{}, pageSize int, max int, fn func(resp *searchResponse) error) error {
+	request := map[string]interface{}{}
+	for k, v := range body {
+		request[k] = v
+	}
+	_, sorted := request["sort"]
+	_, after := request["search_after"]
+	if s.scroll && !after {
+		// scroll does not take search_after
+		return s.scrollPages(request, pageSize, max, fn)
+	}
+	s.mu.Lock()
+	pit, path := s.pit, s.index+"/_search"
+	s.mu.Unlock()
+	if !sorted && (after || max <= 0 || max > pageSize) {
+		// relevance order, with a tiebreaker to page with search_after
+		tiebreaker := "_doc"
+		if pit != "" {
+			tiebreaker = "_shard_doc"
+		}
+		request["sort"] = []interface{}{"_score", tiebreaker}
+	}
+	if pit != "" {
+		path = "_search"
+	}
+	for seen := 0; max <= 0 || seen < max; {
+		size := pageSize
+		if max > 0 && max-seen < size {
+			size = max - seen
+		}
+		request["size"] = size
+		if pit != "" {
+			request["pit"] = map[string]interface{}{"id": pit, "keep_alive": pitKeepAlive}
+		}
+		stream, err := s.client.Stream(goreq.POST, path, request)
+		if err != nil {
+			return err
+		}
+		resp, err := decodeSearch(stream)
+		stream.Close()
+		if err != nil {
+			return err
+		}
+		if resp.PitID != "" {
+			pit = resp.PitID
+			// the latest id is the one to close
+			s.mu.Lock()
+			s.pit = pit
+			s.mu.Unlock()
+		}
+		hits := resp.Hits.Hits
+		if len(hits) == 0 {
+			return nil
+		}
+		if err := fn(resp); err != nil {
+			return err
+		}
+		seen += len(hits)
+		if len(hits) < size {
+			return nil
+		}
+		request["search_after"] = hits[len(hits)-1].Sort
+	}
+	return nil
+}
+
+// scrollPages is pages with the scroll API.
+func (s *searcher) scrollPages(request map[string]interface{}, pageSize int, max int, fn func(resp *searchResponse) error) error {
+	request["size"] = pageSize
+	if max > 0 && max < pageSize {
+		request["size"] = max
+	}
+	stream, err := s.client.Stream(goreq.POST, s.index+"/_search?scroll="+scrollKeepAlive, request)
+	var id string
+	defer func() {
+		if id != "" {
+			s.client.Request(goreq.DELETE, "_search/scroll", map[string]interface{}{"scroll_id": []string{id}})
+		}
+	}()
+	for seen := 0; err == nil; {
+		var resp *searchResponse
+		resp, err = decodeSearch(stream)
+		stream.Close()
+		if err != nil {
+			break
+		}
+		if resp.ScrollID != "" {
+			id = resp.ScrollID
+		}
+		if max > 0 && seen+len(resp.Hits.Hits) > max {
+			resp.Hits.Hits = resp.Hits.Hits[:max-seen]
+		}
+		if len(resp.Hits.Hits) == 0 {
+			return nil
+		}
+		if err = fn(resp); err != nil {
+			break
+		}
+		if seen += len(resp.Hits.Hits); max > 0 && seen >= max {
+			return nil
+		}
+		stream, err = s.client.Stream(goreq.POST, "_search/scroll", map[string]interface{}{"scroll": scrollKeepAlive, "scroll_id": id})
+	}
+	return err
+}
+
+// fieldValue looks up a dotted path in a document source, through objects as well as dotted keys.
+func fieldValue(source map[string]interface{}, path string) interface{} {
+	if v, ok := source[path]; ok {
+		return v
+	}
+	for i := strings.Index(path, "."); i > 0; {
+		if inner, ok := source[path[:i]].(map[string]interface{}); ok {
+			if v := fieldValue(inner, path[i+1:]); v != nil {
+				return v
+			}
+		}
+		next := strings.Index(path[i+1:], ".")
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return nil
+}
+
+// hitsTable lists hits with the given source fields, or their whole source when there are none.
+func hitsTable(hits []hit, fields []string) *Table {
+	t := &Table{Columns: []string{"_index", "_id"}}
+	if len(fields) == 0 {
+		t.Columns = append(t.Columns, "_score", "_source")
+	} else {
+		t.Columns = append(t.Columns, fields...)
+	}
+	for _, h := range hits {
+		row := []interface{}{h.Index, h.ID}
+		if len(fields) == 0 {
+			row = append(row, h.Score, h.Source)
+		}
+		for _, f := range fields {
+			row = append(row, fieldValue(h.Source, f))
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t
+}
@@ -37,6 +37,5 @@ to quickly create a Cobra application.`,
 
 func init() {
 	EsCmd.AddCommand(segmentsCmd)
-
-	segmentsCmd.Flags().StringP("cluster", "c", "localhost:9200", "es cluster")
+	addCatFlags(segmentsCmd)
 }
@@ -0,0 +1,138 @@
+/*
+Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package es
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// settingsCmd represents the es command
+var settingsCmd = &cobra.Command{
+	Use:   "settings <index>",
+	Short: "Display the settings of indices",
+	Long: `Display the settings of the indices matching a pattern, one row per setting by dotted
+path, or nested with --tree. --defaults adds the settings left to their default value, e.g.
+
+  hebe es settings logs-2026.10.16
+  hebe es settings 'logs-*' --tree --defaults`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		tree, err := cmd.Flags().GetBool("tree")
+		check(err)
+		defaults, err := cmd.Flags().GetBool("defaults")
+		check(err)
+		client, err := newClient(cluster)
+		check(err)
+		indices, err := getIndices(client, args[0], defaults)
+		check(err)
+		names := indexNames(indices)
+		if tree {
+			for _, name := range names {
+				data, err := yaml.Marshal(map[string]interface{}{name: nestSettings(indices[name].settings())})
+				check(err)
+				os.Stdout.Write(data)
+			}
+			return
+		}
+		t := &Table{Columns: []string{"index", "setting", "value"}}
+		for _, name := range names {
+			settings := indices[name].settings()
+			for _, k := range sortedKeys(settings) {
+				t.Rows = append(t.Rows, []interface{}{name, k, settings[k]})
+			}
+		}
+		check(t.Write(os.Stdout, outputFormat))
+	},
+}
+
+func init() {
+	EsCmd.AddCommand(settingsCmd)
+	settingsCmd.Flags().Bool("tree", false, "show the settings nested instead of by dotted path")
+	settingsCmd.Flags().Bool("defaults", false, "include the settings left to their default value")
+}
+
+// settings returns the flat settings of an index, including the defaults when they were fetched.
+func (d indexDefinition) settings() map[string]interface{} {
+	settings := flattenSettings(d.Defaults)
+	for k, v := range flattenSettings(d.Settings) {
+		settings[k] = v
+	}
+	return settings
+}
+
+// flattenSettings returns settings by dotted path, whether nested or flat, prefixed with index.
+// as the settings of templates may omit it.
+func flattenSettings(settings map[string]interface{}) map[string]interface{} {
+	flat := map[string]interface{}{}
+	var walk func(prefix string, m map[string]interface{})
+	walk = func(prefix string, m map[string]interface{}) {
+		for k, v := range m {
+			if sub, ok := v.(map[string]interface{}); ok {
+				walk(prefix+k+".", sub)
+				continue
+			}
+			key := prefix + k
+			if !strings.HasPrefix(key, "index.") {
+				key = "index." + key
+			}
+			flat[key] = v
+		}
+	}
+	walk("", settings)
+	return flat
+}
+
+// nestSettings turns flat settings into nested objects, e.g. for YAML.
+func nestSettings(flat map[string]interface{}) map[string]interface{} {
+	nested := map[string]interface{}{}
+	for _, k := range sortedKeys(flat) {
+		m := nested
+		parts := strings.Split(k, ".")
+		for _, part := range parts[:len(parts)-1] {
+			sub, ok := m[part].(map[string]interface{})
+			if !ok {
+				// a setting which is also the prefix of others, e.g. index.routing.allocation.include._tier
+				if v, ok := m[part]; ok {
+					sub = map[string]interface{}{"": v}
+				} else {
+					sub = map[string]interface{}{}
+				}
+				m[part] = sub
+			}
+			m = sub
+		}
+		m[parts[len(parts)-1]] = flat[k]
+	}
+	return nested
+}
+
+// settingValue renders a setting for comparisons, lists included.
+func settingValue(v interface{}) string {
+	if list, ok := v.([]interface{}); ok {
+		values := make([]string, len(list))
+		for i, item := range list {
+			values[i] = fmt.Sprint(item)
+		}
+		return strings.Join(values, ",")
+	}
+	return cell(v)
+}
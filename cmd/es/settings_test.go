@@ -0,0 +1,96 @@
+package es
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNestSettings(t *testing.T) {
+	tests := []struct {
+		name string
+		flat map[string]interface{}
+		want map[string]interface{}
+	}{
+		{
+			name: "nested",
+			flat: map[string]interface{}{
+				"index.number_of_shards":   "3",
+				"index.number_of_replicas": "1",
+				"index.refresh_interval":   "5s",
+			},
+			want: map[string]interface{}{"index": map[string]interface{}{
+				"number_of_shards":   "3",
+				"number_of_replicas": "1",
+				"refresh_interval":   "5s",
+			}},
+		},
+		{
+			name: "setting prefixing others",
+			flat: map[string]interface{}{
+				"index.routing.allocation.include._tier":      "data_hot",
+				"index.routing.allocation.include._tier.both": "x",
+				"index.routing.allocation.require.box":        "hot",
+			},
+			want: map[string]interface{}{"index": map[string]interface{}{
+				"routing": map[string]interface{}{"allocation": map[string]interface{}{
+					"include": map[string]interface{}{"_tier": map[string]interface{}{"": "data_hot", "both": "x"}},
+					"require": map[string]interface{}{"box": "hot"},
+				}},
+			}},
+		},
+		{
+			name: "lists",
+			flat: map[string]interface{}{"index.analysis.filter.stop.stopwords": []interface{}{"a", "the"}},
+			want: map[string]interface{}{"index": map[string]interface{}{"analysis": map[string]interface{}{"filter": map[string]interface{}{
+				"stop": map[string]interface{}{"stopwords": []interface{}{"a", "the"}},
+			}}}},
+		},
+		{name: "empty", flat: map[string]interface{}{}, want: map[string]interface{}{}},
+	}
+	for _, test := range tests {
+		if got := nestSettings(test.flat); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: nestSettings(%v) = %v, want %v", test.name, test.flat, got, test.want)
+		}
+	}
+}
+
+func TestFlattenSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]interface{}
+		want     map[string]interface{}
+	}{
+		{
+			name:     "nested",
+			settings: map[string]interface{}{"index": map[string]interface{}{"number_of_shards": "3", "routing": map[string]interface{}{"allocation": map[string]interface{}{"require": map[string]interface{}{"box": "hot"}}}}},
+			want:     map[string]interface{}{"index.number_of_shards": "3", "index.routing.allocation.require.box": "hot"},
+		},
+		{
+			name:     "flat without index",
+			settings: map[string]interface{}{"number_of_shards": "3", "index.refresh_interval": "5s"},
+			want:     map[string]interface{}{"index.number_of_shards": "3", "index.refresh_interval": "5s"},
+		},
+	}
+	for _, test := range tests {
+		if got := flattenSettings(test.settings); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: flattenSettings(%v) = %v, want %v", test.name, test.settings, got, test.want)
+		}
+	}
+}
+
+func TestSettingValue(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{"5s", "5s"},
+		{[]interface{}{"a", "the"}, "a,the"},
+		{nil, ""},
+		{map[string]interface{}{"a": "b"}, `{"a":"b"}`},
+	}
+	for _, test := range tests {
+		if got := settingValue(test.value); got != test.want {
+			t.Errorf("settingValue(%v) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
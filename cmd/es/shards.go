@@ -16,37 +16,251 @@ limitations under the License.
 package es
 
 import (
-	"github.com/spf13/cobra"
+	"encoding/json"
+	"fmt"
+	"hebe/langs/goreq"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
+
+	"github.com/spf13/cobra"
 )
 
 // shardsCmd represents the es command
 var shardsCmd = &cobra.Command{
 	Use:   "shards",
 	Short: "Detailed view of what nodes contain which shards",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
+	Long: `Detailed view of what nodes contain which shards, their state, documents and size.
 
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+--unassigned only lists the unassigned shards with the reason they became unassigned,
+and --explain asks the cluster why each of them cannot be allocated, grouping them
+by cause with a remedy, e.g.
+
+  hebe es shards --unassigned --explain
+  hebe es shards --unassigned --explain --fix`,
 	Run: func(cmd *cobra.Command, args []string) {
 		cluster := cmd.Flag("cluster").Value.String()
 		index, err := cmd.Flags().GetString("index")
-		if err != nil {
-			panic(err)
-		}
+		check(err)
+		unassigned, err := cmd.Flags().GetBool("unassigned")
+		check(err)
+		explain, err := cmd.Flags().GetBool("explain")
+		check(err)
+		fix, err := cmd.Flags().GetBool("fix")
+		check(err)
+		api := "shards"
 		if len(strings.Trim(index, "")) != 0 {
-			handleCatCommand(cluster, "shards"+"/"+index)
+			api += "/" + index
+		}
+		explain = explain || fix
+		if !unassigned && !explain {
+			handleCatCommand(cluster, api)
+			return
+		}
+		catFlags.where = append(catFlags.where, "state=UNASSIGNED")
+		options := []string{"h=index,shard,prirep,state,unassigned.reason,unassigned.for"}
+		if !explain {
+			handleCatCommand(cluster, api, options...)
+			return
+		}
+		client, err := newClient(cluster)
+		check(err)
+		shards, err := catTable(client, api, options...)
+		check(err)
+		if len(shards.Rows) == 0 {
+			fmt.Fprintln(os.Stderr, "no unassigned shards")
 			return
 		}
-		handleCatCommand(cluster, "shards")
+		groups, err := explainShards(client, shards)
+		check(err)
+		check(allocationTable(groups).Write(os.Stdout, outputFormat))
+		if fix {
+			check(fixAllocation(client, groups))
+		}
 	},
 }
 
 func init() {
 	EsCmd.AddCommand(shardsCmd)
+	addCatFlags(shardsCmd)
 
-	shardsCmd.Flags().StringP("cluster", "c", "localhost:9200", "es cluster")
 	shardsCmd.Flags().StringP("index", "i", "", "index pattern")
+	shardsCmd.Flags().Bool("unassigned", false, "only display unassigned shards")
+	shardsCmd.Flags().Bool("explain", false, "explain why unassigned shards are not allocated, grouped by cause")
+	shardsCmd.Flags().Bool("fix", false, "explain, then retry the allocations which failed too many times")
+	shardsCmd.Flags().BoolVar(&confirmFlags.dryRun, "dry-run", false, "with --fix, show what would be done without doing it")
+}
+
+// allocationExplanation is the part of a _cluster/allocation/explain response used to find the cause.
+type allocationExplanation struct {
+	CanAllocate             string `json:"can_allocate"`
+	AllocateExplanation     string `json:"allocate_explanation"`
+	NodeAllocationDecisions []struct {
+		Deciders []struct {
+			Decider     string `json:"decider"`
+			Decision    string `json:"decision"`
+			Explanation string `json:"explanation"`
+		} `json:"deciders"`
+	} `json:"node_allocation_decisions"`
+}
+
+// Causes of unassigned shards, in the order they are displayed.
+const (
+	causeMaxRetries = "max retries"
+	causeDisabled   = "allocation disabled"
+	causeDisk       = "disk watermark"
+	causeFiltering  = "allocation filtering"
+	causeNodes      = "not enough nodes"
+	causeMissing    = "missing node"
+	causeDelayed    = "delayed allocation"
+	causeThrottled  = "throttled"
+)
+
+var causeOrder = []string{causeMaxRetries, causeDisabled, causeDisk, causeFiltering, causeNodes, causeMissing, causeDelayed, causeThrottled}
+
+var causeRemedies = map[string]string{
+	causeMaxRetries: "fix the failure then POST _cluster/reroute?retry_failed=true, or use --fix",
+	causeDisabled:   "set cluster.routing.allocation.enable to all",
+	causeDisk:       "free disk space or add data nodes, or raise cluster.routing.allocation.disk.watermark.*",
+	causeFiltering:  "check the include, exclude and require settings of index.routing.allocation.* and cluster.routing.allocation.*, and the awareness attributes",
+	causeNodes:      "add data nodes or lower index.number_of_replicas",
+	causeMissing:    "bring back the node holding the data or restore the index from a snapshot, allocate_stale_primary and allocate_empty_primary lose data",
+	causeDelayed:    "wait for the node to come back or for index.unassigned.node_left.delayed_timeout to expire",
+	causeThrottled:  "wait, the cluster is busy with other recoveries",
+}
+
+// deciderCauses maps the allocation deciders to the cause they report.
+var deciderCauses = map[string]string{
+	"max_retry":      causeMaxRetries,
+	"enable":         causeDisabled,
+	"disk_threshold": causeDisk,
+	"filter":         causeFiltering,
+	"awareness":      causeFiltering,
+	"same_shard":     causeNodes,
+}
+
+// cause returns the root cause of an unassigned shard and the explanation backing it.
+func (e *allocationExplanation) cause() (string, string) {
+	switch e.CanAllocate {
+	case "no_valid_shard_copy":
+		return causeMissing, e.AllocateExplanation
+	case "allocation_delayed":
+		return causeDelayed, e.AllocateExplanation
+	case "throttled":
+		return causeThrottled, e.AllocateExplanation
+	}
+	// the decider saying no on most nodes, max retries first as it blocks every node
+	counts := map[string]int{}
+	explanations := map[string]string{}
+	best := ""
+	for _, node := range e.NodeAllocationDecisions {
+		for _, d := range node.Deciders {
+			if d.Decision != "NO" {
+				continue
+			}
+			counts[d.Decider]++
+			if _, ok := explanations[d.Decider]; !ok {
+				explanations[d.Decider] = d.Explanation
+			}
+			if best == "" || counts[d.Decider] > counts[best] {
+				best = d.Decider
+			}
+		}
+	}
+	if counts["max_retry"] > 0 {
+		best = "max_retry"
+	}
+	if best == "" {
+		return e.CanAllocate, e.AllocateExplanation
+	}
+	if cause, ok := deciderCauses[best]; ok {
+		return cause, explanations[best]
+	}
+	return best, explanations[best]
+}
+
+// allocationGroup is the unassigned shards sharing a cause.
+type allocationGroup struct {
+	cause  string
+	detail string
+	shards []string
+}
+
+// explainShards asks the cluster why each shard of a _cat/shards table is unassigned and groups them by cause.
+func explainShards(client *Client, shards *Table) ([]*allocationGroup, error) {
+	groups := map[string]*allocationGroup{}
+	for i := range shards.Rows {
+		index := shards.Value(i, "index")
+		shard, err := strconv.Atoi(shards.Value(i, "shard"))
+		if err != nil {
+			return nil, fmt.Errorf("unexpected shard number for %s: %v", index, err)
+		}
+		primary := shards.Value(i, "prirep") == "p"
+		body := map[string]interface{}{"index": index, "shard": shard, "primary": primary}
+		data, err := client.Request(goreq.POST, "_cluster/allocation/explain", body)
+		if err != nil {
+			return nil, err
+		}
+		var e allocationExplanation
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, err
+		}
+		cause, detail := e.cause()
+		g, ok := groups[cause]
+		if !ok {
+			g = &allocationGroup{cause: cause, detail: detail}
+			groups[cause] = g
+		}
+		g.shards = append(g.shards, fmt.Sprintf("%s[%d]%s", index, shard, shards.Value(i, "prirep")))
+	}
+	var result []*allocationGroup
+	for _, cause := range causeOrder {
+		if g, ok := groups[cause]; ok {
+			result = append(result, g)
+			delete(groups, cause)
+		}
+	}
+	var others []string
+	for cause := range groups {
+		others = append(others, cause)
+	}
+	sort.Strings(others)
+	for _, cause := range others {
+		result = append(result, groups[cause])
+	}
+	return result, nil
+}
+
+func allocationTable(groups []*allocationGroup) *Table {
+	t := &Table{Columns: []string{"cause", "count", "shards", "detail", "remedy"}}
+	for _, g := range groups {
+		remedy, ok := causeRemedies[g.cause]
+		if !ok {
+			remedy = "see the detail"
+		}
+		t.Rows = append(t.Rows, []interface{}{g.cause, len(g.shards), abbreviate(g.shards), g.detail, remedy})
+	}
+	return t
+}
+
+// fixAllocation executes the remedies which are safe to apply unattended, retrying the failed allocations.
+func fixAllocation(client *Client, groups []*allocationGroup) error {
+	for _, g := range groups {
+		if g.cause != causeMaxRetries {
+			continue
+		}
+		path := "_cluster/reroute?retry_failed=true"
+		if confirmFlags.dryRun {
+			printRequest(goreq.POST, path, nil)
+			return nil
+		}
+		if _, err := client.Request(goreq.POST, path, nil); err != nil {
+			return err
+		}
+		fmt.Printf("retrying the allocation of %d shards\n", len(g.shards))
+		return nil
+	}
+	fmt.Fprintln(os.Stderr, "nothing to fix automatically")
+	return nil
 }
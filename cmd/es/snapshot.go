@@ -0,0 +1,686 @@
+/*
+Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package es
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hebe/langs/goreq"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// snapshotCmd represents the es command
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Manage snapshot repositories, snapshots and restores",
+	Long: `Manage snapshot repositories, snapshots and restores, e.g.
+
+  hebe es snapshot repo register backups --type fs --location /mnt/backups
+  hebe es snapshot repo register s3 --type s3 --bucket es-backups --base-path prod
+  hebe es snapshot create backups 'nightly-<{now/d}>' -i 'logs-*' --wait
+  hebe es snapshot list backups --sizes
+  hebe es snapshot restore backups nightly-2026.10.15 -i logs-2026.10.15 --rename-pattern '(.+)' --rename-replacement 'restored-$1'
+  hebe es snapshot progress --watch 2s
+  hebe es snapshot prune backups --match 'nightly-*' --keep-last 7 --older-than 30d`,
+}
+
+var snapshotRepoCmd = &cobra.Command{
+	Use:   "repo",
+	Short: "Manage snapshot repositories",
+}
+
+func init() {
+	EsCmd.AddCommand(snapshotCmd)
+	addConfirmFlags(snapshotCmd)
+
+	snapshotCmd.AddCommand(snapshotRepoCmd)
+	snapshotRepoCmd.AddCommand(repoListCmd, repoRegisterCmd, repoVerifyCmd, repoDeleteCmd)
+	repoRegisterCmd.Flags().String("type", "fs", "repository type: fs, url, s3, or that of another repository plugin")
+	repoRegisterCmd.Flags().String("location", "", "fs: directory listed in path.repo of every node")
+	repoRegisterCmd.Flags().String("url", "", "url: URL of a read-only repository")
+	repoRegisterCmd.Flags().String("bucket", "", "s3: bucket name")
+	repoRegisterCmd.Flags().String("client", "", "s3: client whose endpoint and credentials are set on the nodes, e.g. a MinIO one")
+	repoRegisterCmd.Flags().String("base-path", "", "s3: path of the repository in the bucket")
+	repoRegisterCmd.Flags().Bool("readonly", false, "register the repository read-only, e.g. on a second cluster")
+	repoRegisterCmd.Flags().StringArray("setting", nil, "repository setting as key=value, repeatable")
+	repoRegisterCmd.Flags().Bool("no-verify", false, "do not verify that all nodes can access the repository")
+
+	snapshotCmd.AddCommand(snapshotListCmd, snapshotCreateCmd, snapshotRestoreCmd, snapshotDeleteCmd, snapshotPruneCmd, snapshotProgressCmd)
+	snapshotListCmd.Flags().Bool("sizes", false, "fetch the size of each snapshot, one request per snapshot")
+	snapshotListCmd.Flags().String("match", "", "only list snapshots matching a glob pattern")
+
+	snapshotCreateCmd.Flags().StringP("indices", "i", "", "comma separated index patterns, all indices by default")
+	snapshotCreateCmd.Flags().Bool("wait", false, "wait for the snapshot to complete")
+	snapshotCreateCmd.Flags().Bool("include-global-state", false, "include the cluster state, templates and pipelines")
+	snapshotCreateCmd.Flags().Bool("partial", false, "allow snapshotting indices with unavailable primary shards")
+
+	snapshotRestoreCmd.Flags().StringP("indices", "i", "", "comma separated index patterns, all indices of the snapshot by default")
+	snapshotRestoreCmd.Flags().String("rename-pattern", "", "regular expression matching the restored index names")
+	snapshotRestoreCmd.Flags().String("rename-replacement", "", "replacement of --rename-pattern, e.g. restored-$1")
+	snapshotRestoreCmd.Flags().StringArray("setting", nil, "index setting of the restored indices as key=value, repeatable")
+	snapshotRestoreCmd.Flags().StringArray("ignore-setting", nil, "index setting of the snapshot not to restore, repeatable")
+	snapshotRestoreCmd.Flags().Bool("no-aliases", false, "do not restore the aliases of the indices")
+	snapshotRestoreCmd.Flags().Bool("include-global-state", false, "restore the cluster state, templates and pipelines")
+	snapshotRestoreCmd.Flags().Bool("wait", false, "wait for the restore to complete")
+
+	snapshotPruneCmd.Flags().String("match", "*", "only prune snapshots matching a glob pattern")
+	snapshotPruneCmd.Flags().Int("keep-last", 0, "keep this many of the most recent snapshots")
+	snapshotPruneCmd.Flags().String("older-than", "", "only delete snapshots older than this age, e.g. 30d or 12h")
+
+	snapshotProgressCmd.Flags().StringP("index", "i", "", "index pattern")
+	snapshotProgressCmd.Flags().DurationVar(&catFlags.watch, "watch", 0, "refresh every interval, e.g. 2s, until interrupted")
+	snapshotProgressCmd.Flags().StringArrayVar(&catFlags.until, "until", nil, "with --watch, exit once every row matches <column><op><value>, repeatable")
+}
+
+// keyValues parses key=value flags.
+func keyValues(flags []string) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	for _, f := range flags {
+		i := strings.Index(f, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid setting %q, expected key=value", f)
+		}
+		values[f[:i]] = f[i+1:]
+	}
+	return values, nil
+}
+
+// byteSize formats a number of bytes like the _cat APIs, e.g. 1.2gb.
+func byteSize(n int64) string {
+	units := []string{"b", "kb", "mb", "gb", "tb", "pb"}
+	v := float64(n)
+	i := 0
+	for ; v >= 1024 && i < len(units)-1; i++ {
+		v /= 1024
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d%s", n, units[0])
+	}
+	return strconv.FormatFloat(v, 'f', 1, 64) + units[i]
+}
+
+// parseAge parses a duration, accepting days such as 30d.
+func parseAge(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+var repoListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List snapshot repositories",
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		client, err := newClient(cluster)
+		check(err)
+		repos, err := repositories(client)
+		check(err)
+		t := &Table{Columns: []string{"name", "type", "settings"}}
+		for _, name := range repositoryNames(repos) {
+			t.Rows = append(t.Rows, []interface{}{name, repos[name].Type, repos[name].Settings})
+		}
+		check(t.Write(os.Stdout, outputFormat))
+	},
+}
+
+type repository struct {
+	Type     string                 `json:"type"`
+	Settings map[string]interface{} `json:"settings"`
+}
+
+func repositories(client *Client) (map[string]repository, error) {
+	data, err := client.Request(goreq.GET, "_snapshot", nil)
+	if err != nil {
+		return nil, err
+	}
+	repos := map[string]repository{}
+	return repos, json.Unmarshal(data, &repos)
+}
+
+// repositoryNames returns the sorted names of repositories.
+func repositoryNames(repos map[string]repository) []string {
+	names := make([]string, 0, len(repos))
+	for name := range repos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var repoRegisterCmd = &cobra.Command{
+	Use:   "register <name>",
+	Short: "Register a snapshot repository and verify it",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		typ, err := cmd.Flags().GetString("type")
+		check(err)
+		settingFlags, err := cmd.Flags().GetStringArray("setting")
+		check(err)
+		noVerify, err := cmd.Flags().GetBool("no-verify")
+		check(err)
+		settings, err := keyValues(settingFlags)
+		check(err)
+		for flag, setting := range map[string]string{"location": "location", "url": "url", "bucket": "bucket", "client": "client", "base-path": "base_path"} {
+			if v, _ := cmd.Flags().GetString(flag); v != "" {
+				settings[setting] = v
+			}
+		}
+		if readonly, _ := cmd.Flags().GetBool("readonly"); readonly {
+			settings["readonly"] = true
+		}
+		required := map[string]string{"fs": "location", "url": "url", "s3": "bucket"}[typ]
+		if _, ok := settings[required]; required != "" && !ok {
+			check(fmt.Errorf("--%s is required for %s repositories", required, typ))
+		}
+
+		body := map[string]interface{}{"type": typ, "settings": settings}
+		path := "_snapshot/" + url.PathEscape(args[0]) + "?verify=" + strconv.FormatBool(!noVerify)
+		if confirmFlags.dryRun {
+			printRequest(goreq.PUT, path, body)
+			return
+		}
+		client, err := newClient(cluster)
+		check(err)
+		_, err = client.Request(goreq.PUT, path, body)
+		check(err)
+		fmt.Printf("registered %s\n", args[0])
+	},
+}
+
+var repoVerifyCmd = &cobra.Command{
+	Use:   "verify <name>",
+	Short: "Verify that all nodes can access a snapshot repository",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		client, err := newClient(cluster)
+		check(err)
+		data, err := client.Request(goreq.POST, "_snapshot/"+url.PathEscape(args[0])+"/_verify", nil)
+		check(err)
+		var resp struct {
+			Nodes map[string]struct {
+				Name string `json:"name"`
+			} `json:"nodes"`
+		}
+		check(json.Unmarshal(data, &resp))
+		t := &Table{Columns: []string{"id", "name"}}
+		for id, node := range resp.Nodes {
+			t.Rows = append(t.Rows, []interface{}{id, node.Name})
+		}
+		sort.Slice(t.Rows, func(i, j int) bool { return cell(t.Rows[i][1]) < cell(t.Rows[j][1]) })
+		check(t.Write(os.Stdout, outputFormat))
+	},
+}
+
+var repoDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Unregister a snapshot repository, keeping its snapshots in storage",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		path := "_snapshot/" + url.PathEscape(args[0])
+		if confirmFlags.dryRun {
+			printRequest(goreq.DELETE, path, nil)
+			return
+		}
+		client, err := newClient(cluster)
+		check(err)
+		// production clusters have their name typed instead
+		if !client.cluster.hasTag("production") {
+			ok, err := confirm(fmt.Sprintf("Unregister repository %s?", args[0]), confirmFlags.yes)
+			check(err)
+			if !ok {
+				os.Exit(exitError)
+			}
+		}
+		_, err = client.Request(goreq.DELETE, path, nil)
+		check(err)
+		fmt.Printf("unregistered %s\n", args[0])
+	},
+}
+
+type snapshotInfo struct {
+	Snapshot          string   `json:"snapshot"`
+	State             string   `json:"state"`
+	Indices           []string `json:"indices"`
+	StartTimeInMillis int64    `json:"start_time_in_millis"`
+	EndTimeInMillis   int64    `json:"end_time_in_millis"`
+	Shards            struct {
+		Total      int `json:"total"`
+		Failed     int `json:"failed"`
+		Successful int `json:"successful"`
+	} `json:"shards"`
+	Failures []json.RawMessage `json:"failures"`
+}
+
+func (s *snapshotInfo) started() time.Time {
+	return time.Unix(0, s.StartTimeInMillis*int64(time.Millisecond))
+}
+
+// listSnapshots returns the snapshots of a repository from the oldest to the latest.
+func listSnapshots(client *Client, repo string) ([]snapshotInfo, error) {
+	data, err := client.Request(goreq.GET, "_snapshot/"+url.PathEscape(repo)+"/_all", nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Snapshots []snapshotInfo `json:"snapshots"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(resp.Snapshots, func(i, j int) bool {
+		return resp.Snapshots[i].StartTimeInMillis < resp.Snapshots[j].StartTimeInMillis
+	})
+	return resp.Snapshots, nil
+}
+
+// snapshotSize returns the total size of the files of a snapshot.
+func snapshotSize(client *Client, repo string, snapshot string) (int64, error) {
+	data, err := client.Request(goreq.GET, "_snapshot/"+url.PathEscape(repo)+"/"+url.PathEscape(snapshot)+"/_status", nil)
+	if err != nil {
+		return 0, err
+	}
+	var resp struct {
+		Snapshots []struct {
+			Stats struct {
+				Total struct {
+					SizeInBytes int64 `json:"size_in_bytes"`
+				} `json:"total"`
+				// before 7.4
+				TotalSizeInBytes int64 `json:"total_size_in_bytes"`
+			} `json:"stats"`
+		} `json:"snapshots"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil || len(resp.Snapshots) == 0 {
+		return 0, err
+	}
+	stats := resp.Snapshots[0].Stats
+	if stats.Total.SizeInBytes > 0 {
+		return stats.Total.SizeInBytes, nil
+	}
+	return stats.TotalSizeInBytes, nil
+}
+
+var snapshotListCmd = &cobra.Command{
+	Use:   "list [repository]",
+	Short: "List the snapshots of a repository, or of all repositories",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		sizes, err := cmd.Flags().GetBool("sizes")
+		check(err)
+		match, err := cmd.Flags().GetString("match")
+		check(err)
+		client, err := newClient(cluster)
+		check(err)
+		repos := args
+		if len(repos) == 0 {
+			all, err := repositories(client)
+			check(err)
+			repos = repositoryNames(all)
+		}
+
+		t := &Table{Columns: []string{"repository", "snapshot", "state", "start_time", "duration", "indices", "shards", "failed"}}
+		if sizes {
+			t.Columns = append(t.Columns, "size")
+		}
+		for _, repo := range repos {
+			snapshots, err := listSnapshots(client, repo)
+			check(err)
+			for _, s := range snapshots {
+				if ok, _ := path.Match(match, s.Snapshot); match != "" && !ok {
+					continue
+				}
+				duration := ""
+				if s.EndTimeInMillis > 0 {
+					duration = (time.Duration(s.EndTimeInMillis-s.StartTimeInMillis) * time.Millisecond).Round(time.Second).String()
+				}
+				row := []interface{}{repo, s.Snapshot, s.State, s.started().Format("2006-01-02 15:04:05"), duration,
+					len(s.Indices), fmt.Sprintf("%d/%d", s.Shards.Successful, s.Shards.Total), s.Shards.Failed}
+				if sizes {
+					size, err := snapshotSize(client, repo, s.Snapshot)
+					check(err)
+					row = append(row, byteSize(size))
+				}
+				t.Rows = append(t.Rows, row)
+			}
+		}
+		check(t.Write(os.Stdout, outputFormat))
+	},
+}
+
+// snapshotTable lists the result of a snapshot completed with --wait.
+func snapshotTable(s *snapshotInfo) *Table {
+	t := &Table{Columns: []string{"snapshot", "state", "indices", "shards", "failed", "duration"}}
+	duration := time.Duration(s.EndTimeInMillis-s.StartTimeInMillis) * time.Millisecond
+	t.Rows = append(t.Rows, []interface{}{s.Snapshot, s.State, len(s.Indices),
+		fmt.Sprintf("%d/%d", s.Shards.Successful, s.Shards.Total), s.Shards.Failed, duration.Round(time.Second).String()})
+	return t
+}
+
+var snapshotCreateCmd = &cobra.Command{
+	Use:   "create <repository> <snapshot>",
+	Short: "Create a snapshot of indices",
+	Long: `Create a snapshot of indices. The snapshot name may use date math, e.g.
+'nightly-<{now/d}>'. Without --wait the command returns once the snapshot started,
+and snapshot list shows its state.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		indices, err := cmd.Flags().GetString("indices")
+		check(err)
+		wait, err := cmd.Flags().GetBool("wait")
+		check(err)
+		globalState, err := cmd.Flags().GetBool("include-global-state")
+		check(err)
+		partial, err := cmd.Flags().GetBool("partial")
+		check(err)
+
+		body := map[string]interface{}{"include_global_state": globalState, "partial": partial}
+		if indices != "" {
+			body["indices"] = indices
+		}
+		path := "_snapshot/" + url.PathEscape(args[0]) + "/" + url.PathEscape(args[1]) + "?wait_for_completion=" + strconv.FormatBool(wait)
+		if confirmFlags.dryRun {
+			printRequest(goreq.PUT, path, body)
+			return
+		}
+		client, err := newClient(cluster)
+		check(err)
+		data, err := client.Request(goreq.PUT, path, body)
+		check(err)
+		if !wait {
+			fmt.Printf("started snapshot %s\n", args[1])
+			return
+		}
+		var resp struct {
+			Snapshot snapshotInfo `json:"snapshot"`
+		}
+		check(json.Unmarshal(data, &resp))
+		check(snapshotTable(&resp.Snapshot).Write(os.Stdout, outputFormat))
+		if resp.Snapshot.State != "SUCCESS" {
+			for _, f := range resp.Snapshot.Failures {
+				fmt.Fprintf(os.Stderr, "%s\n", f)
+			}
+			os.Exit(exitError)
+		}
+	},
+}
+
+var snapshotRestoreCmd = &cobra.Command{
+	Use:   "restore <repository> <snapshot>",
+	Short: "Restore indices from a snapshot",
+	Long: `Restore indices from a snapshot. Indices of the same name must be closed or
+deleted first, unless restored under another name with --rename-pattern and
+--rename-replacement. Follow the restore with snapshot progress.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		indices, err := cmd.Flags().GetString("indices")
+		check(err)
+		renamePattern, err := cmd.Flags().GetString("rename-pattern")
+		check(err)
+		renameReplacement, err := cmd.Flags().GetString("rename-replacement")
+		check(err)
+		settingFlags, err := cmd.Flags().GetStringArray("setting")
+		check(err)
+		ignoreSettings, err := cmd.Flags().GetStringArray("ignore-setting")
+		check(err)
+		noAliases, err := cmd.Flags().GetBool("no-aliases")
+		check(err)
+		globalState, err := cmd.Flags().GetBool("include-global-state")
+		check(err)
+		wait, err := cmd.Flags().GetBool("wait")
+		check(err)
+
+		if (renamePattern == "") != (renameReplacement == "") {
+			check(errors.New("--rename-pattern and --rename-replacement go together"))
+		}
+		body := map[string]interface{}{"include_aliases": !noAliases, "include_global_state": globalState}
+		if indices != "" {
+			body["indices"] = indices
+		}
+		if renamePattern != "" {
+			body["rename_pattern"] = renamePattern
+			body["rename_replacement"] = renameReplacement
+		}
+		if len(settingFlags) > 0 {
+			settings, err := keyValues(settingFlags)
+			check(err)
+			body["index_settings"] = settings
+		}
+		if len(ignoreSettings) > 0 {
+			body["ignore_index_settings"] = ignoreSettings
+		}
+		path := "_snapshot/" + url.PathEscape(args[0]) + "/" + url.PathEscape(args[1]) + "/_restore?wait_for_completion=" + strconv.FormatBool(wait)
+		if confirmFlags.dryRun {
+			printRequest(goreq.POST, path, body)
+			return
+		}
+		client, err := newClient(cluster)
+		check(err)
+		data, err := client.Request(goreq.POST, path, body)
+		check(err)
+		if !wait {
+			fmt.Printf("started restoring %s, follow it with: hebe es snapshot progress --watch 2s\n", args[1])
+			return
+		}
+		var resp struct {
+			Snapshot struct {
+				Indices []string `json:"indices"`
+				Shards  struct {
+					Total      int `json:"total"`
+					Failed     int `json:"failed"`
+					Successful int `json:"successful"`
+				} `json:"shards"`
+			} `json:"snapshot"`
+		}
+		check(json.Unmarshal(data, &resp))
+		fmt.Printf("restored %s, %d of %d shards\n", strings.Join(resp.Snapshot.Indices, ", "), resp.Snapshot.Shards.Successful, resp.Snapshot.Shards.Total)
+		if resp.Snapshot.Shards.Failed > 0 {
+			os.Exit(exitError)
+		}
+	},
+}
+
+var snapshotDeleteCmd = &cobra.Command{
+	Use:   "delete <repository> <snapshot>...",
+	Short: "Delete snapshots",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		client, err := newClient(cluster)
+		check(err)
+		check(deleteSnapshots(client, args[0], args[1:]))
+	},
+}
+
+// deleteSnapshots deletes snapshots one by one, after confirmation.
+func deleteSnapshots(client *Client, repo string, snapshots []string) error {
+	if confirmFlags.dryRun {
+		for _, s := range snapshots {
+			printRequest(goreq.DELETE, "_snapshot/"+url.PathEscape(repo)+"/"+url.PathEscape(s), nil)
+		}
+		return nil
+	}
+	for _, s := range snapshots {
+		fmt.Fprintln(os.Stderr, s)
+	}
+	// production clusters have their name typed instead
+	if !client.cluster.hasTag("production") {
+		ok, err := confirm(fmt.Sprintf("Delete %d snapshots from %s?", len(snapshots), repo), confirmFlags.yes)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			os.Exit(exitError)
+		}
+	}
+	for _, s := range snapshots {
+		if _, err := client.Request(goreq.DELETE, "_snapshot/"+url.PathEscape(repo)+"/"+url.PathEscape(s), nil); err != nil {
+			return err
+		}
+		fmt.Printf("deleted %s\n", s)
+	}
+	return nil
+}
+
+var snapshotPruneCmd = &cobra.Command{
+	Use:   "prune <repository>",
+	Short: "Delete old snapshots by retention rules",
+	Long: `Delete the snapshots matching --match except the --keep-last latest ones, and
+only those older than --older-than when given, e.g.
+
+  hebe es snapshot prune backups --match 'nightly-*' --keep-last 7 --older-than 30d --dry-run
+
+Snapshots in progress are never deleted.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		match, err := cmd.Flags().GetString("match")
+		check(err)
+		keepLast, err := cmd.Flags().GetInt("keep-last")
+		check(err)
+		olderThan, err := cmd.Flags().GetString("older-than")
+		check(err)
+		if keepLast <= 0 && olderThan == "" {
+			check(errors.New("--keep-last or --older-than is required"))
+		}
+		var age time.Duration
+		if olderThan != "" {
+			age, err = parseAge(olderThan)
+			check(err)
+		}
+
+		client, err := newClient(cluster)
+		check(err)
+		snapshots, err := listSnapshots(client, args[0])
+		check(err)
+		var matching []snapshotInfo
+		for _, s := range snapshots {
+			if ok, _ := path.Match(match, s.Snapshot); ok && s.State != "IN_PROGRESS" {
+				matching = append(matching, s)
+			}
+		}
+		var prune []string
+		for i, s := range matching {
+			latest := len(matching) - i
+			if latest > keepLast && (age == 0 || time.Since(s.started()) > age) {
+				prune = append(prune, s.Snapshot)
+			}
+		}
+		if len(prune) == 0 {
+			fmt.Fprintln(os.Stderr, "no snapshots to delete")
+			return
+		}
+		check(deleteSnapshots(client, args[0], prune))
+	},
+}
+
+var snapshotProgressCmd = &cobra.Command{
+	Use:   "progress",
+	Short: "Show the progress of snapshot restores",
+	Long: `Show the shards of the indices restored from snapshots, with the progress of
+their recoveries: primaries are restored from the snapshot, then replicas copied
+from the primaries. For example, until all shards are started:
+
+  hebe es snapshot progress --watch 5s --until state=STARTED`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		index, err := cmd.Flags().GetString("index")
+		check(err)
+		check(checkWatchFlags())
+		client, err := newClient(cluster)
+		check(err)
+		fetch := func() (*Table, error) {
+			return restoreProgress(client, index)
+		}
+		if catFlags.watch > 0 {
+			watchTable(client, "restore progress", fetch)
+			return
+		}
+		t, err := fetch()
+		check(err)
+		check(t.Write(os.Stdout, outputFormat))
+	},
+}
+
+// restoreProgress joins the shards of the indices with snapshot recoveries to their recoveries.
+func restoreProgress(client *Client, index string) (*Table, error) {
+	api := "recovery"
+	if index != "" {
+		api += "/" + index
+	}
+	recoveries, err := callCatRequest(client, api, "h=index,shard,type,stage,target_node,snapshot,files_percent,bytes_percent,time")
+	if err != nil {
+		return nil, err
+	}
+	restored := map[string]bool{}
+	var names []string
+	for i := range recoveries.Rows {
+		if name := recoveries.Value(i, "index"); recoveries.Value(i, "type") == "snapshot" && !restored[name] {
+			restored[name] = true
+			names = append(names, name)
+		}
+	}
+	t := &Table{Columns: []string{"index", "shard", "prirep", "state", "node", "type", "stage", "snapshot", "files_percent", "bytes_percent", "time"}}
+	if len(names) == 0 {
+		return t, nil
+	}
+	sort.Strings(names)
+	shards := &Table{}
+	for _, chunk := range indexChunks(names) {
+		part, err := callCatRequest(client, "shards/"+indexPath(chunk), "h=index,shard,prirep,state,node", "s=index,shard,prirep")
+		if err != nil {
+			return nil, err
+		}
+		shards.Columns = part.Columns
+		shards.Rows = append(shards.Rows, part.Rows...)
+	}
+	// a shard copy is recovered on its node
+	latest := map[string]int{}
+	for i := range recoveries.Rows {
+		latest[recoveries.Value(i, "index")+"/"+recoveries.Value(i, "shard")+"/"+recoveries.Value(i, "target_node")] = i
+	}
+	for i := range shards.Rows {
+		row := []interface{}{shards.Value(i, "index"), shards.Value(i, "shard"), shards.Value(i, "prirep"), shards.Value(i, "state"), shards.Value(i, "node")}
+		if r, ok := latest[shards.Value(i, "index")+"/"+shards.Value(i, "shard")+"/"+shards.Value(i, "node")]; ok {
+			for _, column := range t.Columns[5:] {
+				row = append(row, recoveries.Value(r, column))
+			}
+		} else {
+			row = append(row, make([]interface{}, len(t.Columns)-5)...)
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t, nil
+}
@@ -0,0 +1,99 @@
+// +build integration
+
+package es
+
+import (
+	"encoding/json"
+	"fmt"
+	"hebe/langs/goreq"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// TestS3Repository snapshots and restores an index through an S3-compatible repository, e.g.
+// a local MinIO. It runs with the integration build tag against the cluster of HEBE_TEST_CLUSTER,
+// whose nodes have the repository-s3 plugin and an S3 client, minio unless HEBE_TEST_S3_CLIENT,
+// with the endpoint and keystore credentials of an existing bucket, hebe-test unless
+// HEBE_TEST_S3_BUCKET:
+//
+//   docker run -d -p 9000:9000 minio/minio server /data
+//   # s3.client.minio.endpoint: localhost:9000, s3.client.minio.protocol: http, and
+//   # s3.client.minio.access_key and secret_key in the keystore of each node
+//   HEBE_TEST_CLUSTER=localhost:9200 go test -tags integration -run S3 ./cmd/es
+//
+// The commands exit the test binary on errors, as they do the command line.
+func TestS3Repository(t *testing.T) {
+	hosts := os.Getenv("HEBE_TEST_CLUSTER")
+	if hosts == "" {
+		t.Skip("HEBE_TEST_CLUSTER is not set")
+	}
+	bucket := os.Getenv("HEBE_TEST_S3_BUCKET")
+	if bucket == "" {
+		bucket = "hebe-test"
+	}
+	s3Client := os.Getenv("HEBE_TEST_S3_CLIENT")
+	if s3Client == "" {
+		s3Client = "minio"
+	}
+	suffix := fmt.Sprint(time.Now().Unix())
+	repo, snapshot, index := "hebe-test-"+suffix, "snapshot-"+suffix, "hebe-test-"+suffix
+	viper.Set("clusters", map[string]interface{}{
+		"test": map[string]interface{}{
+			"hosts":             strings.Split(hosts, ","),
+			"protected_indices": []string{index},
+		},
+	})
+	defer viper.Set("clusters", nil)
+	run := func(args ...string) {
+		t.Helper()
+		// the root command of hebe runs es
+		EsCmd.Root().SetArgs(append(append([]string{"es"}, args...), "--cluster", "test"))
+		if err := EsCmd.Root().Execute(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client, err := newClient("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Request(goreq.PUT, index+"/_doc/1?refresh=true", map[string]interface{}{"message": "hello"}); err == nil {
+		t.Fatalf("indexing into protected %s succeeded", index)
+	}
+	client.cluster.ProtectedIndices = nil
+	if _, err := client.Request(goreq.PUT, index+"/_doc/1?refresh=true", map[string]interface{}{"message": "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Request(goreq.DELETE, index, nil)
+
+	run("snapshot", "repo", "register", repo, "--type", "s3", "--bucket", bucket, "--client", s3Client, "--base-path", repo)
+	defer run("snapshot", "repo", "delete", repo, "--yes")
+	run("snapshot", "repo", "verify", repo)
+	run("snapshot", "create", repo, snapshot, "--indices", index, "--wait")
+	defer run("snapshot", "delete", repo, snapshot, "--yes")
+
+	// restoring over the protected index is refused, under another name it is not
+	protected, err := newClient("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := protected.Request(goreq.POST, "_snapshot/"+repo+"/"+snapshot+"/_restore", map[string]interface{}{"indices": index}); err == nil || !strings.Contains(err.Error(), "protected") {
+		t.Fatalf("restoring protected %s: %v", index, err)
+	}
+	run("snapshot", "restore", repo, snapshot, "--indices", index, "--rename-pattern", "(.+)", "--rename-replacement", "restored-$1", "--wait")
+	defer client.Request(goreq.DELETE, "restored-"+index, nil)
+
+	data, err := client.Request(goreq.GET, "restored-"+index+"/_count", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count struct {
+		Count int `json:"count"`
+	}
+	if err := json.Unmarshal(data, &count); err != nil || count.Count != 1 {
+		t.Fatalf("restored %s counts %s", index, data)
+	}
+}
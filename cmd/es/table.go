@@ -0,0 +1,226 @@
+package es
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Output formats accepted by --output.
+var outputFormats = []string{"table", "json", "ndjson", "yaml", "csv", "tsv"}
+
+// Table is a list of rows decoded from a JSON array of objects, keeping the column order of the response.
+type Table struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+func decodeTable(body []byte) (*Table, error) {
+	var objects []json.RawMessage
+	if err := json.Unmarshal(body, &objects); err != nil {
+		return nil, fmt.Errorf("unexpected response: %v", err)
+	}
+	t := &Table{}
+	index := map[string]int{}
+	for _, raw := range objects {
+		keys, values, err := decodeObject(raw)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range keys {
+			if _, ok := index[k]; !ok {
+				index[k] = len(t.Columns)
+				t.Columns = append(t.Columns, k)
+			}
+		}
+		row := make([]interface{}, len(t.Columns))
+		for k, v := range values {
+			row[index[k]] = v
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	// rows decoded before a late column appeared are shorter than the header
+	for i, row := range t.Rows {
+		for len(row) < len(t.Columns) {
+			row = append(row, nil)
+		}
+		t.Rows[i] = row
+	}
+	return t, nil
+}
+
+// decodeObject decodes a JSON object, returning its keys in document order.
+func decodeObject(raw []byte) ([]string, map[string]interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	if _, err := d.Token(); err != nil {
+		return nil, nil, err
+	}
+	var keys []string
+	values := map[string]interface{}{}
+	for d.More() {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, nil, fmt.Errorf("unexpected response: expected an object")
+		}
+		var v interface{}
+		if err := d.Decode(&v); err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, key)
+		values[key] = v
+	}
+	return keys, values, nil
+}
+
+// Value returns the cell of row i in column, or "" when the table has no such column.
+func (t *Table) Value(i int, column string) string {
+	for j, c := range t.Columns {
+		if c == column {
+			return cell(t.Rows[i][j])
+		}
+	}
+	return ""
+}
+
+// cell formats a value for text outputs.
+func cell(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// Write renders the table in one of outputFormats.
+func (t *Table) Write(w io.Writer, format string) error {
+	switch format {
+	case "", "table":
+		return t.writeText(w)
+	case "json":
+		return t.writeJSON(w, false)
+	case "ndjson":
+		return t.writeJSON(w, true)
+	case "yaml":
+		return t.writeYAML(w)
+	case "csv":
+		return t.writeCSV(w, ',')
+	case "tsv":
+		return t.writeCSV(w, '\t')
+	}
+	return fmt.Errorf("unknown output format %q, expected one of %s", format, strings.Join(outputFormats, ", "))
+}
+
+func (t *Table) writeText(w io.Writer) error {
+	if len(t.Columns) == 0 {
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(t.Columns, "\t"))
+	for _, row := range t.Rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cells[i] = cell(v)
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+func (t *Table) object(row []interface{}) []byte {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, column := range t.Columns {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, _ := json.Marshal(column)
+		v, _ := json.Marshal(row[i])
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes()
+}
+
+func (t *Table) writeJSON(w io.Writer, lines bool) error {
+	var b bytes.Buffer
+	if !lines {
+		b.WriteByte('[')
+	}
+	for i, row := range t.Rows {
+		if i > 0 && !lines {
+			b.WriteByte(',')
+		}
+		b.Write(t.object(row))
+		if lines {
+			b.WriteByte('\n')
+		}
+	}
+	if !lines {
+		var out bytes.Buffer
+		b.WriteByte(']')
+		if err := json.Indent(&out, b.Bytes(), "", "  "); err != nil {
+			return err
+		}
+		out.WriteByte('\n')
+		b = out
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func (t *Table) writeYAML(w io.Writer) error {
+	docs := make([]yaml.MapSlice, len(t.Rows))
+	for i, row := range t.Rows {
+		for j, column := range t.Columns {
+			v := row[j]
+			if n, ok := v.(json.Number); ok {
+				v = n.String()
+			}
+			docs[i] = append(docs[i], yaml.MapItem{Key: column, Value: v})
+		}
+	}
+	b, err := yaml.Marshal(docs)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func (t *Table) writeCSV(w io.Writer, comma rune) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	if err := cw.Write(t.Columns); err != nil {
+		return err
+	}
+	for _, row := range t.Rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cells[i] = cell(v)
+		}
+		if err := cw.Write(cells); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
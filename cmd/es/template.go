@@ -0,0 +1,506 @@
+/*
+Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package es
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hebe/langs/goreq"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// templateCmd represents the es command
+var templateCmd = &cobra.Command{
+	Use:   "template",
+	Short: "Manage index, component and legacy templates",
+	Long: `Manage composable index templates, component templates and legacy templates, e.g.
+
+  hebe es template list
+  hebe es template get logs > logs.json
+  hebe es template put logs -f logs.json
+  hebe es template simulate logs-2026.10.16
+  hebe es template apply -f templates/ --dry-run
+
+--kind selects component, composable or legacy templates; put defaults to composable,
+the other commands look for a template of any kind.`,
+}
+
+func init() {
+	EsCmd.AddCommand(templateCmd)
+	addConfirmFlags(templateCmd)
+	templateCmd.PersistentFlags().String("kind", "", "kind of templates: component, composable or legacy")
+	templateCmd.AddCommand(templateListCmd, templateGetCmd, templatePutCmd, templateDeleteCmd, templateSimulateCmd, templateApplyCmd)
+	templatePutCmd.Flags().StringP("file", "f", "-", "JSON file of the template, - for stdin")
+	templateApplyCmd.Flags().StringP("file", "f", "", "directory of <name>.json template files")
+	templateApplyCmd.MarkFlagRequired("file")
+}
+
+// templateKind is one of the kinds of templates and its API.
+type templateKind struct {
+	name     string
+	endpoint string
+	listKey  string // the list of templates of the response, none for legacy templates keyed by name
+	defKey   string // the definition of each template of the list
+}
+
+// templateKinds in the order they are applied, index templates being composed of component ones.
+var templateKinds = []templateKind{
+	{"component", "_component_template", "component_templates", "component_template"},
+	{"composable", "_index_template", "index_templates", "index_template"},
+	{"legacy", "_template", "", ""},
+}
+
+// kindsFor returns the kinds selected by --kind, all of them when empty.
+func kindsFor(kind string) ([]templateKind, error) {
+	if kind == "" {
+		return templateKinds, nil
+	}
+	for _, k := range templateKinds {
+		if k.name == kind {
+			return []templateKind{k}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown template kind %q, expected component, composable or legacy", kind)
+}
+
+type template struct {
+	name string
+	kind templateKind
+	def  map[string]interface{}
+}
+
+// listTemplates returns the templates of a kind matching pattern, all of them when empty.
+func listTemplates(client *Client, kind templateKind, pattern string) ([]template, error) {
+	p := kind.endpoint
+	if pattern != "" {
+		p += "/" + pattern
+	}
+	data, err := client.Request(goreq.GET, p, nil)
+	if e, ok := err.(*ResponseError); ok && (e.Status == 404 || e.Status == 400 && kind.listKey != "") {
+		// no match, or a version before 7.8 without composable and component templates
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var resp map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&resp); err != nil {
+		return nil, err
+	}
+	var templates []template
+	if kind.listKey == "" {
+		for name, def := range resp {
+			def, _ := def.(map[string]interface{})
+			templates = append(templates, template{name, kind, def})
+		}
+	} else {
+		list, _ := resp[kind.listKey].([]interface{})
+		for _, item := range list {
+			item, _ := item.(map[string]interface{})
+			def, _ := item[kind.defKey].(map[string]interface{})
+			templates = append(templates, template{cell(item["name"]), kind, def})
+		}
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].name < templates[j].name })
+	return templates, nil
+}
+
+// findTemplate returns the template named name among the kinds selected by --kind, which must be a single one.
+func findTemplate(client *Client, kind string, name string) (*template, error) {
+	kinds, err := kindsFor(kind)
+	if err != nil {
+		return nil, err
+	}
+	var found []template
+	for _, k := range kinds {
+		templates, err := listTemplates(client, k, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range templates {
+			if t.name == name {
+				found = append(found, t)
+			}
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("template %s not found", name)
+	case 1:
+		return &found[0], nil
+	}
+	var names []string
+	for _, t := range found {
+		names = append(names, t.kind.name)
+	}
+	return nil, fmt.Errorf("%s is both a %s template, choose one with --kind", name, strings.Join(names, " and a "))
+}
+
+var templateListCmd = &cobra.Command{
+	Use:   "list [pattern]",
+	Short: "List templates",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		kind, err := cmd.Flags().GetString("kind")
+		check(err)
+		kinds, err := kindsFor(kind)
+		check(err)
+		pattern := ""
+		if len(args) > 0 {
+			pattern = args[0]
+		}
+		client, err := newClient(cluster)
+		check(err)
+		t := &Table{Columns: []string{"name", "kind", "index_patterns", "priority", "version", "composed_of"}}
+		for _, k := range kinds {
+			templates, err := listTemplates(client, k, pattern)
+			check(err)
+			for _, tpl := range templates {
+				priority := tpl.def["priority"]
+				if k.name == "legacy" {
+					priority = tpl.def["order"]
+				}
+				t.Rows = append(t.Rows, []interface{}{tpl.name, k.name, settingValue(tpl.def["index_patterns"]), priority, tpl.def["version"], settingValue(tpl.def["composed_of"])})
+			}
+		}
+		check(t.Write(os.Stdout, outputFormat))
+	},
+}
+
+var templateGetCmd = &cobra.Command{
+	Use:   "get <name>",
+	Short: "Print the definition of a template, as accepted by put",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		kind, err := cmd.Flags().GetString("kind")
+		check(err)
+		client, err := newClient(cluster)
+		check(err)
+		t, err := findTemplate(client, kind, args[0])
+		check(err)
+		data, err := json.MarshalIndent(t.def, "", "  ")
+		check(err)
+		fmt.Printf("%s\n", data)
+	},
+}
+
+var templatePutCmd = &cobra.Command{
+	Use:   "put <name>",
+	Short: "Create or replace a template",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		kind, err := cmd.Flags().GetString("kind")
+		check(err)
+		file, err := cmd.Flags().GetString("file")
+		check(err)
+		if kind == "" {
+			kind = "composable"
+		}
+		kinds, err := kindsFor(kind)
+		check(err)
+		body, err := readJSON(file)
+		check(err)
+		path := kinds[0].endpoint + "/" + args[0]
+		if confirmFlags.dryRun {
+			printRequest(goreq.PUT, path, body)
+			return
+		}
+		client, err := newClient(cluster)
+		check(err)
+		_, err = client.Request(goreq.PUT, path, body)
+		check(err)
+		fmt.Printf("put %s template %s\n", kind, args[0])
+	},
+}
+
+var templateDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a template",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		kind, err := cmd.Flags().GetString("kind")
+		check(err)
+		client, err := newClient(cluster)
+		check(err)
+		t, err := findTemplate(client, kind, args[0])
+		check(err)
+		path := t.kind.endpoint + "/" + t.name
+		if confirmFlags.dryRun {
+			printRequest(goreq.DELETE, path, nil)
+			return
+		}
+		// production clusters have their name typed instead
+		if !client.cluster.hasTag("production") {
+			ok, err := confirm(fmt.Sprintf("Delete %s template %s?", t.kind.name, t.name), confirmFlags.yes)
+			check(err)
+			if !ok {
+				os.Exit(exitError)
+			}
+		}
+		_, err = client.Request(goreq.DELETE, path, nil)
+		check(err)
+		fmt.Printf("deleted %s template %s\n", t.kind.name, t.name)
+	},
+}
+
+var templateSimulateCmd = &cobra.Command{
+	Use:   "simulate <index>",
+	Short: "Show the settings, mappings and aliases templates would give a new index",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		client, err := newClient(cluster)
+		check(err)
+		data, err := client.Request(goreq.POST, "_index_template/_simulate_index/"+args[0], nil)
+		check(err)
+		var resp struct {
+			Template    indexDefinition `json:"template"`
+			Overlapping []struct {
+				Name string `json:"name"`
+			} `json:"overlapping"`
+		}
+		check(json.Unmarshal(data, &resp))
+		t := &Table{Columns: []string{"section", "key", "value"}}
+		settings := flattenSettings(resp.Template.Settings)
+		for _, k := range sortedKeys(settings) {
+			t.Rows = append(t.Rows, []interface{}{"settings", k, settings[k]})
+		}
+		for _, f := range flattenMapping(resp.Template.mappings()) {
+			t.Rows = append(t.Rows, []interface{}{"mappings", f.field, f.definition()})
+		}
+		for _, alias := range sortedKeys(resp.Template.Aliases) {
+			t.Rows = append(t.Rows, []interface{}{"aliases", alias, resp.Template.Aliases[alias]})
+		}
+		check(t.Write(os.Stdout, outputFormat))
+		if len(resp.Overlapping) > 0 {
+			var names []string
+			for _, o := range resp.Overlapping {
+				names = append(names, o.Name)
+			}
+			fmt.Fprintf(os.Stderr, "overlapping templates of lower priority: %s\n", strings.Join(names, ", "))
+		}
+	},
+}
+
+var templateApplyCmd = &cobra.Command{
+	Use:   "apply -f <dir>",
+	Short: "Sync a directory of template files to the cluster",
+	Long: `Sync a directory of <name>.json template files to the cluster, showing the differences with
+the templates of the cluster first. Only the templates which differ are put, component
+templates first. The kind of each template is told from its content unless --kind is given:
+component templates have a template but no index_patterns, legacy ones an order or
+top-level settings, mappings or aliases.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		kind, err := cmd.Flags().GetString("kind")
+		check(err)
+		dir, err := cmd.Flags().GetString("file")
+		check(err)
+		_, err = kindsFor(kind)
+		check(err)
+		desired, err := readTemplates(dir, kind)
+		check(err)
+		client, err := newClient(cluster)
+		check(err)
+		changes, err := planTemplates(client, desired)
+		check(err)
+		if len(changes) == 0 {
+			fmt.Fprintf(os.Stderr, "%d templates up to date\n", len(desired))
+			return
+		}
+		check(templateChanges(changes).Write(os.Stdout, outputFormat))
+		if confirmFlags.dryRun {
+			return
+		}
+		// production clusters have their name typed instead
+		if !client.cluster.hasTag("production") {
+			ok, err := confirm(fmt.Sprintf("Put %d templates?", len(changes)), confirmFlags.yes)
+			check(err)
+			if !ok {
+				os.Exit(exitError)
+			}
+		}
+		for _, c := range changes {
+			_, err := client.Request(goreq.PUT, c.kind.endpoint+"/"+c.name, c.def)
+			check(err)
+			fmt.Printf("put %s template %s\n", c.kind.name, c.name)
+		}
+	},
+}
+
+// readTemplates reads the <name>.json files of dir, in the order of templateKinds then by name.
+func readTemplates(dir string, kind string) ([]template, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no template files in %s", dir)
+	}
+	var templates []template
+	for _, file := range files {
+		def, err := readJSON(file)
+		if err != nil {
+			return nil, err
+		}
+		k := kind
+		if k == "" {
+			k = detectTemplateKind(def)
+		}
+		kinds, _ := kindsFor(k)
+		templates = append(templates, template{strings.TrimSuffix(filepath.Base(file), ".json"), kinds[0], def})
+	}
+	order := map[string]int{}
+	for i, k := range templateKinds {
+		order[k.name] = i
+	}
+	sort.SliceStable(templates, func(i, j int) bool {
+		return order[templates[i].kind.name] < order[templates[j].kind.name]
+	})
+	return templates, nil
+}
+
+// detectTemplateKind tells the kind of a template from its content.
+func detectTemplateKind(def map[string]interface{}) string {
+	_, patterns := def["index_patterns"]
+	_, tpl := def["template"]
+	switch {
+	case tpl && !patterns:
+		return "component"
+	case tpl:
+		return "composable"
+	}
+	for _, k := range []string{"order", "settings", "mappings", "aliases"} {
+		if _, ok := def[k]; ok {
+			return "legacy"
+		}
+	}
+	return "composable"
+}
+
+// templateChange is a template to put and how it differs from the one of the cluster, nil when new.
+type templateChange struct {
+	template
+	diff [][]interface{}
+}
+
+// planTemplates compares templates to those of the cluster, returning the ones to put.
+func planTemplates(client *Client, desired []template) ([]templateChange, error) {
+	var changes []templateChange
+	for _, t := range desired {
+		current, err := listTemplates(client, t.kind, t.name)
+		if err != nil {
+			return nil, err
+		}
+		var existing *template
+		for i := range current {
+			if current[i].name == t.name {
+				existing = &current[i]
+			}
+		}
+		if existing == nil {
+			changes = append(changes, templateChange{template: t})
+			continue
+		}
+		if diff := diffValues(t.kind.name+" "+t.name, flattenTemplate(existing.def), flattenTemplate(t.def), nil); len(diff) > 0 {
+			changes = append(changes, templateChange{t, diff})
+		}
+	}
+	return changes, nil
+}
+
+// templateChanges renders the differences of the templates to put, new templates in one row.
+func templateChanges(changes []templateChange) *Table {
+	t := &Table{Columns: []string{"template", "key", "current", "desired", "change"}}
+	for _, c := range changes {
+		if c.diff == nil {
+			t.Rows = append(t.Rows, []interface{}{c.kind.name + " " + c.name, nil, nil, nil, "create"})
+			continue
+		}
+		t.Rows = append(t.Rows, c.diff...)
+	}
+	return t
+}
+
+// flattenTemplate renders a template by dotted path for comparisons, its settings and mappings
+// normalized like those of indices.
+func flattenTemplate(def map[string]interface{}) map[string]string {
+	flat := flattenObject(def, func(flat map[string]string, prefix string, k string, v map[string]interface{}) bool {
+		switch k {
+		case "settings":
+			for s, value := range flattenSettings(v) {
+				flat[prefix+"settings."+s] = settingValue(value)
+			}
+		case "mappings":
+			for _, f := range flattenMapping(typelessMappings(v)) {
+				flat[prefix+"mappings."+f.field] = f.definition()
+			}
+		default:
+			return false
+		}
+		return true
+	})
+	// the default order of legacy templates
+	if flat["order"] == "0" {
+		delete(flat, "order")
+	}
+	return flat
+}
+
+// flattenObject renders an object by dotted path for comparisons, its values and lists as JSON and
+// its empty objects as {}, e.g. an alias without filter. Nulls, empty lists and the empty sections
+// the cluster adds to a definition or its template are left out. expand renders the objects it
+// normalizes, returning false for the others.
+func flattenObject(def map[string]interface{}, expand func(flat map[string]string, prefix string, k string, v map[string]interface{}) bool) map[string]string {
+	flat := map[string]string{}
+	var walk func(prefix string, m map[string]interface{})
+	walk = func(prefix string, m map[string]interface{}) {
+		for k, v := range m {
+			switch v := v.(type) {
+			case nil:
+			case map[string]interface{}:
+				switch {
+				case expand != nil && expand(flat, prefix, k, v):
+				case len(v) > 0:
+					walk(prefix+k+".", v)
+				case prefix != "" && prefix != "template.":
+					flat[prefix+k] = "{}"
+				}
+			case []interface{}:
+				if len(v) > 0 {
+					flat[prefix+k] = cell(v)
+				}
+			default:
+				flat[prefix+k] = cell(v)
+			}
+		}
+	}
+	walk("", def)
+	return flat
+}
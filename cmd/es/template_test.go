@@ -0,0 +1,94 @@
+package es
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFlattenTemplate(t *testing.T) {
+	tests := []struct {
+		name string
+		def  map[string]interface{}
+		want map[string]string
+	}{
+		{
+			name: "index template",
+			def: map[string]interface{}{
+				"index_patterns": []interface{}{"logs-*"},
+				"priority":       100.0,
+				"composed_of":    []interface{}{},
+				"_meta":          nil,
+				"template": map[string]interface{}{
+					"settings": map[string]interface{}{"index": map[string]interface{}{"number_of_shards": "1"}},
+					"mappings": map[string]interface{}{"properties": map[string]interface{}{
+						"message": map[string]interface{}{"type": "text"},
+						"user": map[string]interface{}{"properties": map[string]interface{}{
+							"name": map[string]interface{}{"type": "keyword", "ignore_above": 256.0},
+						}},
+					}},
+					"aliases": map[string]interface{}{
+						"logs":   map[string]interface{}{},
+						"errors": map[string]interface{}{"filter": map[string]interface{}{"term": map[string]interface{}{"level": "error"}}},
+					},
+				},
+			},
+			want: map[string]string{
+				"index_patterns": `["logs-*"]`,
+				"priority":       "100",
+				"template.settings.index.number_of_shards":  "1",
+				"template.mappings.message":                 "text",
+				"template.mappings.user":                    "object",
+				"template.mappings.user.name":               `keyword {"ignore_above":256}`,
+				"template.aliases.logs":                     "{}",
+				"template.aliases.errors.filter.term.level": "error",
+			},
+		},
+		{
+			name: "legacy template",
+			def: map[string]interface{}{
+				"order":          0.0,
+				"index_patterns": []interface{}{"metrics-*"},
+				"settings":       map[string]interface{}{"index": map[string]interface{}{"refresh_interval": "5s"}},
+				"mappings":       map[string]interface{}{"_doc": map[string]interface{}{"properties": map[string]interface{}{"value": map[string]interface{}{"type": "long"}}}},
+				"aliases":        map[string]interface{}{},
+			},
+			want: map[string]string{
+				"index_patterns":                  `["metrics-*"]`,
+				"settings.index.refresh_interval": "5s",
+				"mappings.value":                  "long",
+			},
+		},
+		{
+			name: "legacy template with an order",
+			def:  map[string]interface{}{"order": 1.0, "index_patterns": []interface{}{"metrics-*"}},
+			want: map[string]string{"order": "1", "index_patterns": `["metrics-*"]`},
+		},
+		{
+			name: "component template",
+			def: map[string]interface{}{"template": map[string]interface{}{
+				"aliases":  map[string]interface{}{},
+				"mappings": map[string]interface{}{},
+				"settings": map[string]interface{}{"number_of_replicas": "0"},
+			}},
+			want: map[string]string{"template.settings.index.number_of_replicas": "0"},
+		},
+	}
+	for _, test := range tests {
+		if got := flattenTemplate(test.def); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: flattenTemplate = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestTemplateDifferences(t *testing.T) {
+	current := flattenTemplate(map[string]interface{}{"template": map[string]interface{}{
+		"aliases": map[string]interface{}{"logs": map[string]interface{}{}},
+	}})
+	desired := flattenTemplate(map[string]interface{}{"template": map[string]interface{}{
+		"aliases": map[string]interface{}{"logs": map[string]interface{}{}, "all": map[string]interface{}{}},
+	}})
+	want := [][]interface{}{{"template logs", "template.aliases.all", nil, "{}", "added"}}
+	if got := diffValues("template logs", current, desired, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffValues = %v, want %v", got, want)
+	}
+}
@@ -37,6 +37,6 @@ to quickly create a Cobra application.`,
 
 func init() {
 	EsCmd.AddCommand(threadsCmd)
-
-	threadsCmd.Flags().StringP("cluster", "c", "localhost:9200", "es cluster")
+	addCatFlags(threadsCmd)
+	addCheckFlags(threadsCmd)
 }
@@ -0,0 +1,442 @@
+/*
+Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package es
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// topCmd represents the es command
+var topCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Interactive dashboard of cluster health, nodes, thread pools and pending tasks",
+	Long: `Full screen dashboard refreshing cluster health, per node heap, cpu, load and
+disk, thread pools with activity or rejections, pending tasks and indices.
+
+Keys:
+  tab          switch between the nodes and indices panes
+  up/down, k/j select a node or index
+  enter        show the shards and thread pools of a node, or the shards and aliases of an index
+  esc          back to the overview
+  r            refresh now
+  q            quit`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := cmd.Flag("cluster").Value.String()
+		interval, err := cmd.Flags().GetDuration("interval")
+		check(err)
+		if interval <= 0 {
+			check(fmt.Errorf("--interval must be positive, got %s", interval))
+		}
+		if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+			check(fmt.Errorf("top needs a terminal, use --watch on the _cat commands instead"))
+		}
+		client, err := newClient(cluster)
+		check(err)
+		check(runTop(client, interval))
+	},
+}
+
+func init() {
+	EsCmd.AddCommand(topCmd)
+
+	topCmd.Flags().Duration("interval", 2*time.Second, "refresh interval")
+}
+
+const (
+	altScreen  = "\x1b[?1049h\x1b[?25l"
+	mainScreen = "\x1b[?25h\x1b[?1049l"
+	clearLine  = "\x1b[K"
+)
+
+var statusColors = map[string]string{
+	"green":  "\x1b[32m",
+	"yellow": "\x1b[33m",
+	"red":    "\x1b[31m",
+}
+
+// healthOrder sorts indices worst first.
+var healthOrder = map[string]int{"red": 0, "yellow": 1, "green": 2}
+
+const (
+	nodesPane = iota
+	indicesPane
+)
+
+type topScreen struct {
+	client   *Client
+	interval time.Duration
+	// node or index being shown, in the pane it was selected from, or "" for the overview
+	detail   string
+	pane     int
+	selected [2]int
+	tables   map[string]*Table
+	err      error
+	updated  time.Time
+}
+
+func runTop(client *Client, interval time.Duration) error {
+	restore, err := rawTerminal()
+	if err != nil {
+		return err
+	}
+	os.Stdout.WriteString(altScreen)
+	defer func() {
+		os.Stdout.WriteString(mainScreen)
+		restore()
+	}()
+
+	keys := make(chan string)
+	go readKeys(keys)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGHUP)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	s := &topScreen{client: client, interval: interval}
+	s.refresh()
+	s.draw()
+	for {
+		select {
+		case <-ticker.C:
+			s.refresh()
+		case key, ok := <-keys:
+			if !ok {
+				// stdin is closed, refresh on the ticker only
+				keys = nil
+				continue
+			}
+			switch key {
+			case "q", "\x03":
+				return nil
+			case "r":
+				s.refresh()
+			case "\t":
+				if s.detail == "" {
+					s.pane = 1 - s.pane
+				}
+			case "k", "\x1b[A":
+				s.move(-1)
+			case "j", "\x1b[B":
+				s.move(1)
+			case "\r", "\n":
+				if s.detail == "" {
+					s.detail = s.selection()
+					s.refresh()
+				}
+			case "\x1b", "\x7f":
+				if s.detail != "" {
+					s.detail = ""
+					s.refresh()
+				}
+			}
+		case <-signals:
+			return nil
+		}
+		s.draw()
+	}
+}
+
+// rawTerminal puts the terminal in raw mode, returning a function restoring it.
+func rawTerminal() (func(), error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("stty: %v", err)
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, fmt.Errorf("stty: %v", err)
+	}
+	return func() { stty(strings.TrimSpace(saved)) }, nil
+}
+
+func stty(args ...string) (string, error) {
+	c := exec.Command("stty", args...)
+	c.Stdin = os.Stdin
+	out, err := c.Output()
+	return string(out), err
+}
+
+func terminalSize() (int, int) {
+	out, err := stty("size")
+	if err == nil {
+		var rows, cols int
+		if _, err := fmt.Sscan(out, &rows, &cols); err == nil && rows > 0 && cols > 0 {
+			return rows, cols
+		}
+	}
+	return 24, 80
+}
+
+func readKeys(keys chan<- string) {
+	buf := make([]byte, 16)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			close(keys)
+			return
+		}
+		keys <- string(buf[:n])
+	}
+}
+
+func (s *topScreen) move(delta int) {
+	list := s.paneTable(s.pane)
+	if s.detail != "" || list == nil || len(list.Rows) == 0 {
+		return
+	}
+	i := s.selected[s.pane] + delta
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(list.Rows) {
+		i = len(list.Rows) - 1
+	}
+	s.selected[s.pane] = i
+}
+
+func (s *topScreen) paneTable(pane int) *Table {
+	if pane == nodesPane {
+		return s.tables["nodes"]
+	}
+	return s.tables["indices"]
+}
+
+func (s *topScreen) selection() string {
+	list := s.paneTable(s.pane)
+	if list == nil || s.selected[s.pane] >= len(list.Rows) {
+		return ""
+	}
+	if s.pane == nodesPane {
+		return list.Value(s.selected[s.pane], "name")
+	}
+	return list.Value(s.selected[s.pane], "index")
+}
+
+func (s *topScreen) refresh() {
+	queries := [][]string{
+		{"health", "health", "h=cluster,status,node.total,node.data,shards,pri,relo,init,unassign,pending_tasks"},
+		{"threads", "thread_pool", "h=node_name,name,active,queue,rejected"},
+	}
+	switch {
+	case s.detail == "":
+		queries = append(queries,
+			[]string{"nodes", "nodes", "h=name,ip,heap.percent,ram.percent,cpu,load_1m,node.role,master", "s=name"},
+			[]string{"allocation", "allocation", "h=node,shards,disk.percent"},
+			[]string{"pending", "pending_tasks", "h=insertOrder,timeInQueue,priority,source"},
+			[]string{"indices", "indices", "h=health,status,index,pri,rep,docs.count,store.size", "s=index"})
+	case s.pane == nodesPane:
+		queries = append(queries, []string{"shards", "shards", "h=index,shard,prirep,state,docs,store,node", "s=index,shard"})
+	default:
+		queries = append(queries,
+			[]string{"indices", "indices/" + s.detail, "h=health,status,index,uuid,pri,rep,docs.count,docs.deleted,store.size,pri.store.size"},
+			[]string{"shards", "shards/" + s.detail, "h=shard,prirep,state,docs,store,node,unassigned.reason", "s=shard,prirep"},
+			[]string{"aliases", "aliases", "h=alias,index,filter,routing.index,routing.search,is_write_index"})
+	}
+	tables := map[string]*Table{}
+	for _, q := range queries {
+		t, err := callCatRequest(s.client, q[1], q[2:]...)
+		if err != nil {
+			s.err = err
+			return
+		}
+		tables[q[0]] = t
+	}
+	s.tables, s.err, s.updated = tables, nil, time.Now()
+
+	if nodes, allocation := tables["nodes"], tables["allocation"]; nodes != nil && allocation != nil {
+		joinColumns(nodes, "name", allocation, "node", "shards", "disk.percent")
+	}
+	keep(tables["threads"], func(t *Table, i int) bool {
+		if s.detail != "" && s.pane == nodesPane && t.Value(i, "node_name") != s.detail {
+			return false
+		}
+		return t.Value(i, "active") != "0" || t.Value(i, "queue") != "0" || t.Value(i, "rejected") != "0"
+	})
+	if s.detail != "" && s.pane == nodesPane {
+		keep(tables["shards"], func(t *Table, i int) bool { return t.Value(i, "node") == s.detail })
+	}
+	if s.detail != "" && s.pane == indicesPane {
+		keep(tables["aliases"], func(t *Table, i int) bool { return t.Value(i, "index") == s.detail })
+	}
+	if indices := tables["indices"]; indices != nil && s.detail == "" {
+		health := columnIndex(indices, "health")
+		sort.SliceStable(indices.Rows, func(i, j int) bool {
+			return healthOrder[cell(indices.Rows[i][health])] < healthOrder[cell(indices.Rows[j][health])]
+		})
+	}
+	for pane := range s.selected {
+		if t := s.paneTable(pane); t != nil && s.selected[pane] >= len(t.Rows) {
+			s.selected[pane] = 0
+		}
+	}
+}
+
+func columnIndex(t *Table, column string) int {
+	for i, c := range t.Columns {
+		if c == column {
+			return i
+		}
+	}
+	return -1
+}
+
+// keep removes the rows of t for which f is false.
+func keep(t *Table, f func(t *Table, i int) bool) {
+	if t == nil {
+		return
+	}
+	var rows [][]interface{}
+	for i, row := range t.Rows {
+		if f(t, i) {
+			rows = append(rows, row)
+		}
+	}
+	t.Rows = rows
+}
+
+// joinColumns appends columns of other to t, matching t's key column with other's otherKey.
+func joinColumns(t *Table, key string, other *Table, otherKey string, columns ...string) {
+	values := map[string][]interface{}{}
+	for i := range other.Rows {
+		var row []interface{}
+		for _, column := range columns {
+			row = append(row, other.Value(i, column))
+		}
+		values[other.Value(i, otherKey)] = row
+	}
+	t.Columns = append(t.Columns, columns...)
+	for i, row := range t.Rows {
+		extra, ok := values[t.Value(i, key)]
+		if !ok {
+			extra = make([]interface{}, len(columns))
+		}
+		t.Rows[i] = append(row, extra...)
+	}
+}
+
+func (s *topScreen) draw() {
+	height, width := terminalSize()
+	var lines []string
+	add := func(line string) {
+		lines = append(lines, truncate(line, width))
+	}
+
+	title := fmt.Sprintf("hebe top - %s", s.client.cluster.Name)
+	status := ""
+	if health := s.tables["health"]; health != nil && len(health.Rows) > 0 {
+		title += " (" + health.Value(0, "cluster") + ")"
+		status = health.Value(0, "status")
+		add(fmt.Sprintf("nodes %s data %s | shards %s pri %s | relo %s init %s unassign %s | pending %s",
+			health.Value(0, "node.total"), health.Value(0, "node.data"), health.Value(0, "shards"), health.Value(0, "pri"),
+			health.Value(0, "relo"), health.Value(0, "init"), health.Value(0, "unassign"), health.Value(0, "pending_tasks")))
+	}
+	header := fmt.Sprintf("%s  %s  every %s", title, s.updated.Format("15:04:05"), s.interval)
+	if s.err != nil {
+		lines = append(lines, "error: "+s.err.Error())
+	}
+	lines = append(lines, "")
+
+	// rows left for the panes, below the header and above the footer
+	free := height - len(lines) - 3
+	section := func(name string, t *Table, selected int, max int) {
+		if t == nil {
+			return
+		}
+		if max > free {
+			max = free
+		}
+		if max < 2 {
+			return
+		}
+		add(fmt.Sprintf("%s (%d)", name, len(t.Rows)))
+		table := t.alignedLines(nil)
+		add(table[0])
+		rows := table[1:]
+		shown := max - 2
+		if shown > len(rows) {
+			shown = len(rows)
+		}
+		start := 0
+		if selected >= shown {
+			start = selected - shown + 1
+		}
+		for i := start; i < start+shown; i++ {
+			line := truncate(rows[i], width)
+			if i == selected {
+				line = highlight + line + strings.Repeat(" ", width-len(line)) + reset
+			}
+			lines = append(lines, line)
+		}
+		free -= shown + 2
+	}
+	selected := func(pane int) int {
+		if s.pane == pane {
+			return s.selected[pane]
+		}
+		return -1
+	}
+
+	switch {
+	case s.detail == "":
+		section("Nodes", s.tables["nodes"], selected(nodesPane), free/3+2)
+		section("Thread pools with activity", s.tables["threads"], -1, 8)
+		section("Pending tasks", s.tables["pending"], -1, 7)
+		section("Indices", s.tables["indices"], selected(indicesPane), free)
+	case s.pane == nodesPane:
+		header += "  node " + s.detail
+		section("Thread pools with activity", s.tables["threads"], -1, 10)
+		section("Shards", s.tables["shards"], -1, free)
+	default:
+		header += "  index " + s.detail
+		section("Index", s.tables["indices"], -1, 3)
+		section("Aliases", s.tables["aliases"], -1, 6)
+		section("Shards", s.tables["shards"], -1, free)
+	}
+
+	footer := "q quit  tab switch pane  up/down select  enter details  r refresh"
+	if s.detail != "" {
+		footer = "q quit  esc back  r refresh"
+	}
+	header = truncate(header, width)
+	if color, ok := statusColors[status]; ok {
+		header += "  " + color + strings.ToUpper(status) + reset
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	b.WriteString(header + clearLine + "\r\n")
+	for _, line := range lines {
+		b.WriteString(line + clearLine + "\r\n")
+	}
+	b.WriteString("\x1b[J")
+	b.WriteString(fmt.Sprintf("\x1b[%d;1H%s%s", height, truncate(footer, width), clearLine))
+	os.Stdout.WriteString(b.String())
+}
+
+func truncate(line string, width int) string {
+	if len(line) > width {
+		return line[:width]
+	}
+	return line
+}
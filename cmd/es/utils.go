@@ -1,25 +1,156 @@
 package es
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"hebe/langs/goreq"
+	"io/ioutil"
+	"net/url"
+	"os"
 	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
 )
 
+// catFlags are the options shared by every _cat command, see addCatFlags.
+var catFlags struct {
+	columns string
+	sort    string
+	bytes   string
+	time    string
+	where   []string
+	limit   int
+	watch   time.Duration
+	until   []string
+}
+
+func addCatFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&catFlags.columns, "columns", "", "comma separated columns to display, overrides the default h= list, `*` for all")
+	cmd.Flags().StringVar(&catFlags.sort, "sort", "", "comma separated columns to sort by server side, e.g. store.size:desc")
+	cmd.Flags().StringVar(&catFlags.bytes, "bytes", "", "unit for byte values: b, kb, mb, gb, tb, pb")
+	cmd.Flags().StringVar(&catFlags.time, "time", "", "unit for time values: d, h, m, s, ms, micros, nanos")
+	cmd.Flags().StringArrayVar(&catFlags.where, "where", nil, "keep rows matching <column><op><value>, op one of "+strings.Join(operators, " ")+", repeatable")
+	cmd.Flags().IntVar(&catFlags.limit, "limit", 0, "display at most this many rows")
+	cmd.Flags().DurationVar(&catFlags.watch, "watch", 0, "refresh every interval, e.g. 2s, until interrupted")
+	cmd.Flags().StringArrayVar(&catFlags.until, "until", nil, "with --watch, exit once every row matches <column><op><value>, repeatable")
+}
+
+// checkWatchFlags rejects --until without --watch, which would otherwise be ignored.
+func checkWatchFlags() error {
+	if len(catFlags.until) > 0 && catFlags.watch <= 0 {
+		return errors.New("--until needs --watch")
+	}
+	return nil
+}
+
 func handleCatCommand(cluster string, cmd string, options ...string) {
-	body := callCatRequest(cluster, cmd, options...)
-	fmt.Println(body)
+	check(checkWatchFlags())
+	if checkFlags.check || checkFlags.prometheus != "" {
+		checkCatCommand(cluster, cmd, options...)
+		return
+	}
+	client, err := newClient(cluster)
+	check(err)
+	if catFlags.watch > 0 {
+		watchCatCommand(client, cmd, options...)
+		return
+	}
+	table, err := catTable(client, cmd, options...)
+	check(err)
+	check(table.Write(os.Stdout, outputFormat))
+}
+
+// catTable calls a _cat API and keeps the rows selected by --where and --limit.
+func catTable(client *Client, api string, options ...string) (*Table, error) {
+	table, err := callCatRequest(client, api, options...)
+	if err != nil {
+		return nil, err
+	}
+	if err := table.Filter(catFlags.where); err != nil {
+		return nil, err
+	}
+	table.Limit(catFlags.limit)
+	return table, nil
 }
 
-func callCatRequest(endpoint string, api string, options ...string) string {
-	uri := fmt.Sprintf("http://%s/_cat/%s?v", endpoint, api)
-	if len(options) > 0 {
-		uri += "&" + strings.Join(options, "&")
+func callCatRequest(client *Client, api string, options ...string) (*Table, error) {
+	query, err := url.ParseQuery(strings.Join(options, "&"))
+	if err != nil {
+		return nil, err
+	}
+	query.Set("format", "json")
+	for k, v := range map[string]string{"h": catFlags.columns, "s": catFlags.sort, "bytes": catFlags.bytes, "time": catFlags.time} {
+		if v != "" {
+			query.Set(k, v)
+		}
 	}
-	r := goreq.New()
-	_, body, errs := r.Get(uri).End()
-	if len(errs) > 0 {
-		panic(errs[0])
+	body, err := client.Request(goreq.GET, "_cat/"+api+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTable(body)
+}
+
+// confirmFlags are the options of the command groups changing the cluster, see addConfirmFlags.
+var confirmFlags struct {
+	dryRun bool
+	yes    bool
+}
+
+func addConfirmFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().BoolVar(&confirmFlags.dryRun, "dry-run", false, "show what would be done without doing it")
+	cmd.PersistentFlags().BoolVarP(&confirmFlags.yes, "yes", "y", false, "do not ask for confirmation")
+}
+
+// readJSON decodes a JSON object from a file, or from stdin when name is "-".
+func readJSON(name string) (map[string]interface{}, error) {
+	var data []byte
+	var err error
+	if name == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = readFile(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var v map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid JSON in %s: %v", name, err)
+	}
+	return v, nil
+}
+
+// confirm asks a yes or no question on the terminal, answered beforehand by yes.
+func confirm(question string, yes bool) (bool, error) {
+	if yes {
+		return true, nil
+	}
+	if !isTerminal(os.Stdin) {
+		return false, errors.New("cannot ask for confirmation without a terminal, confirm with --yes")
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		fmt.Fprintln(os.Stderr)
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes", nil
+}
+
+// maxListed is the number of items listed by name in a message, see abbreviate.
+const maxListed = 5
+
+// abbreviate joins the first maxListed items, counting the others.
+func abbreviate(items []string) string {
+	if len(items) <= maxListed {
+		return strings.Join(items, ", ")
 	}
-	return body
+	return fmt.Sprintf("%s and %d more", strings.Join(items[:maxListed], ", "), len(items)-maxListed)
 }
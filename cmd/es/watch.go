@@ -0,0 +1,143 @@
+package es
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	clearScreen = "\x1b[H\x1b[2J"
+	highlight   = "\x1b[1;7m"
+	reset       = "\x1b[0m"
+)
+
+// watchCatCommand calls a _cat API every --watch interval with the same client.
+// On a terminal with table output the screen is redrawn in place and cells which
+// changed since the previous refresh are highlighted; otherwise every refresh is
+// appended to stdout. Errors are shown and retried, so that the watch survives
+// nodes restarting. With --until it exits once all rows match.
+func watchCatCommand(client *Client, api string, options ...string) {
+	watchTable(client, api, func() (*Table, error) {
+		return catTable(client, api, options...)
+	})
+}
+
+// watchTable refreshes the table returned by fetch like watchCatCommand, with title in the header.
+func watchTable(client *Client, title string, fetch func() (*Table, error)) {
+	redraw := (outputFormat == "" || outputFormat == "table") && isTerminal(os.Stdout)
+	var previous *Table
+	for {
+		table, err := fetch()
+		var out bytes.Buffer
+		if redraw {
+			out.WriteString(clearScreen)
+			fmt.Fprintf(&out, "Every %s: %s on %s\t%s\n\n", catFlags.watch, title, client.cluster.Name, time.Now().Format("15:04:05"))
+		}
+		switch {
+		case err != nil:
+			fmt.Fprintln(&out, "error:", err)
+		case redraw:
+			table.writeChanges(&out, previous)
+			previous = table
+		default:
+			check(table.Write(&out, outputFormat))
+		}
+		os.Stdout.Write(out.Bytes())
+
+		if err == nil && len(catFlags.until) > 0 {
+			done, err := table.Match(catFlags.until)
+			check(err)
+			if done {
+				return
+			}
+		}
+		time.Sleep(catFlags.watch)
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// rowKeys identifies rows across refreshes by their first cell, numbered when the
+// first cell repeats, e.g. the shards of one index.
+func (t *Table) rowKeys() []string {
+	keys := make([]string, len(t.Rows))
+	seen := map[string]int{}
+	for i, row := range t.Rows {
+		first := ""
+		if len(row) > 0 {
+			first = cell(row[0])
+		}
+		keys[i] = fmt.Sprintf("%s#%d", first, seen[first])
+		seen[first]++
+	}
+	return keys
+}
+
+// writeChanges writes the table aligned, highlighting the cells which differ from previous.
+func (t *Table) writeChanges(out *bytes.Buffer, previous *Table) {
+	for _, line := range t.alignedLines(previous) {
+		out.WriteString(line + "\n")
+	}
+}
+
+// alignedLines formats the header and rows in aligned columns. When previous is
+// not nil, the cells which differ from it are highlighted.
+func (t *Table) alignedLines(previous *Table) []string {
+	old := map[string]map[string]string{}
+	if previous != nil {
+		for i, key := range previous.rowKeys() {
+			values := map[string]string{}
+			for j, column := range previous.Columns {
+				values[column] = cell(previous.Rows[i][j])
+			}
+			old[key] = values
+		}
+	}
+	widths := make([]int, len(t.Columns))
+	for i, column := range t.Columns {
+		widths[i] = len(column)
+	}
+	for _, row := range t.Rows {
+		for i, v := range row {
+			if n := len(cell(v)); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	line := func(cells []string, changed []bool) string {
+		var b strings.Builder
+		for i, c := range cells {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			padding := ""
+			if i < len(cells)-1 {
+				padding = strings.Repeat(" ", widths[i]-len(c))
+			}
+			if changed != nil && changed[i] {
+				b.WriteString(highlight + c + reset + padding)
+			} else {
+				b.WriteString(c + padding)
+			}
+		}
+		return b.String()
+	}
+	lines := []string{line(t.Columns, nil)}
+	for i, key := range t.rowKeys() {
+		cells := make([]string, len(t.Columns))
+		changed := make([]bool, len(t.Columns))
+		values, seen := old[key]
+		for j, column := range t.Columns {
+			cells[j] = cell(t.Rows[i][j])
+			changed[j] = previous != nil && (!seen || values[column] != cells[j])
+		}
+		lines = append(lines, line(cells, changed))
+	}
+	return lines
+}
@@ -8,4 +8,5 @@ require (
 	github.com/spf13/cobra v0.0.5
 	github.com/spf13/viper v1.4.0
 	golang.org/x/net v0.0.0-20190522155817-f3200d17e092
+	gopkg.in/yaml.v2 v2.2.2
 )
@@ -244,6 +244,7 @@ func (s *SuperAgent) AddCookies(cookies []*http.Cookie) *SuperAgent {
 var Types = map[string]string{
 	"html":       "text/html",
 	"json":       "application/json",
+	"ndjson":     "application/x-ndjson",
 	"xml":        "application/xml",
 	"text":       "text/plain",
 	"urlencoded": "application/x-www-form-urlencoded",
@@ -266,6 +267,7 @@ var Types = map[string]string{
 //
 //    "text/html" uses "html"
 //    "application/json" uses "json"
+//    "application/x-ndjson" uses "ndjson"
 //    "application/xml" uses "xml"
 //    "text/plain" uses "text"
 //    "application/x-www-form-urlencoded" uses "urlencoded", "form" or "form-data"
@@ -633,18 +635,75 @@ func (s *SuperAgent) End(callback ...func(response Response, body string, errs [
 
 // EndBytes should be used when you want the body as bytes. The callbacks work the same way as with `End`, except that a byte array is used instead of a string.
 func (s *SuperAgent) EndBytes(callback ...func(response Response, body []byte, errs []error)) (Response, []byte, []error) {
-	var (
-		req  *http.Request
-		err  error
-		resp Response
-	)
 	// check whether there is an error. if yes, return all errors
 	if len(s.Errors) != 0 {
 		return nil, nil, s.Errors
 	}
+	resp, err := s.send()
+	if err != nil {
+		s.Errors = append(s.Errors, err)
+		return nil, nil, s.Errors
+	}
+	defer resp.Body.Close()
+
+	// Log details of this response
+	if s.Debug {
+		dump, err := httputil.DumpResponse(resp, true)
+		if nil != err {
+			s.logger.Println("Error:", err)
+		} else {
+			s.logger.Printf("HTTP Response: %s", string(dump))
+		}
+	}
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	// Reset resp.Body so it can be use again
+	resp.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+	// deep copy response to give it to both return and callback func
+	respCallback := *resp
+	if len(callback) != 0 {
+		callback[0](&respCallback, body, s.Errors)
+	}
+	return resp, body, nil
+}
+
+// EndStream sends the request like End, but returns the response with its Body still open,
+// for reading large responses without buffering them. The caller must close the Body.
+//
+//    resp, errs := gorequest.New().Get("http://example.com/large.ndjson").EndStream()
+//    if errs != nil {
+//      return errs
+//    }
+//    defer resp.Body.Close()
+//    io.Copy(os.Stdout, resp.Body)
+//
+func (s *SuperAgent) EndStream() (Response, []error) {
+	if len(s.Errors) != 0 {
+		return nil, s.Errors
+	}
+	resp, err := s.send()
+	if err != nil {
+		s.Errors = append(s.Errors, err)
+		return nil, s.Errors
+	}
+
+	// Log details of this response, without the body which is left to the caller
+	if s.Debug {
+		dump, err := httputil.DumpResponse(resp, false)
+		if nil != err {
+			s.logger.Println("Error:", err)
+		} else {
+			s.logger.Printf("HTTP Response: %s", string(dump))
+		}
+	}
+	return resp, nil
+}
+
+// send makes the request and sends it, returning the response with an unread body.
+func (s *SuperAgent) send() (*http.Response, error) {
 	// check if there is forced type
 	switch s.ForceType {
-	case "json", "form", "xml", "text":
+	case "json", "ndjson", "form", "xml", "text":
 		s.TargetType = s.ForceType
 		// If forcetype is not set, check whether user set Content-Type header.
 		// If yes, also bounce to the correct supported TargetType automatically.
@@ -662,10 +721,9 @@ func (s *SuperAgent) EndBytes(callback ...func(response Response, body []byte, e
 	}
 
 	// Make Request
-	req, err = s.MakeRequest()
+	req, err := s.MakeRequest()
 	if err != nil {
-		s.Errors = append(s.Errors, err)
-		return nil, nil, s.Errors
+		return nil, err
 	}
 
 	// Set Transport
@@ -696,32 +754,7 @@ func (s *SuperAgent) EndBytes(callback ...func(response Response, body []byte, e
 	}
 
 	// Send request
-	resp, err = s.Client.Do(req)
-	if err != nil {
-		s.Errors = append(s.Errors, err)
-		return nil, nil, s.Errors
-	}
-	defer resp.Body.Close()
-
-	// Log details of this response
-	if s.Debug {
-		dump, err := httputil.DumpResponse(resp, true)
-		if nil != err {
-			s.logger.Println("Error:", err)
-		} else {
-			s.logger.Printf("HTTP Response: %s", string(dump))
-		}
-	}
-
-	body, _ := ioutil.ReadAll(resp.Body)
-	// Reset resp.Body so it can be use again
-	resp.Body = ioutil.NopCloser(bytes.NewBuffer(body))
-	// deep copy response to give it to both return and callback func
-	respCallback := *resp
-	if len(callback) != 0 {
-		callback[0](&respCallback, body, s.Errors)
-	}
-	return resp, body, nil
+	return s.Client.Do(req)
 }
 
 func (s *SuperAgent) MakeRequest() (*http.Request, error) {
@@ -731,7 +764,15 @@ func (s *SuperAgent) MakeRequest() (*http.Request, error) {
 	)
 
 	switch s.Method {
-	case POST, PUT, PATCH:
+	case POST, PUT, PATCH, DELETE:
+		if s.Method == DELETE && len(s.Data) == 0 && len(s.SliceData) == 0 && s.RawString == "" {
+			// DELETE only carries a body when one was sent
+			req, err = http.NewRequest(s.Method, s.Url, nil)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
 		if s.TargetType == "json" {
 			// If-case to give support to json array. we check if
 			// 1) Map only: send it as json map from s.Data
@@ -767,6 +808,10 @@ func (s *SuperAgent) MakeRequest() (*http.Request, error) {
 		} else if s.TargetType == "text" {
 			req, err = http.NewRequest(s.Method, s.Url, strings.NewReader(s.RawString))
 			req.Header.Set("Content-Type", "text/plain")
+		} else if s.TargetType == "ndjson" {
+			// newline delimited JSON, e.g. bulk requests, is sent as is
+			req, err = http.NewRequest(s.Method, s.Url, strings.NewReader(s.RawString))
+			req.Header.Set("Content-Type", "application/x-ndjson")
 		} else if s.TargetType == "xml" {
 			req, err = http.NewRequest(s.Method, s.Url, strings.NewReader(s.RawString))
 			req.Header.Set("Content-Type", "application/xml")
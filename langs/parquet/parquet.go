@@ -0,0 +1,294 @@
+// Package parquet writes flat tables of optional UTF8 string columns as Apache Parquet files.
+//
+// It only implements what exporting documents needs: every column is an optional
+// BYTE_ARRAY annotated as UTF8, values are PLAIN encoded and uncompressed, and each
+// row group is written as one data page per column.
+//
+//	w := parquet.NewWriter(f, []string{"id", "name"})
+//	name := "kimchy"
+//	w.Write([]*string{&id, &name})
+//	w.Write([]*string{&id, nil})
+//	w.Close()
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const magic = "PAR1"
+
+// DefaultRowGroupSize is the number of rows buffered before a row group is written.
+var DefaultRowGroupSize = 50000
+
+// Parquet enums, see parquet.thrift
+const (
+	typeByteArray      = 6
+	repetitionOptional = 1
+	convertedUTF8      = 0
+	encodingPlain      = 0
+	encodingRLE        = 3
+	codecUncompressed  = 0
+	pageData           = 0
+)
+
+type columnChunk struct {
+	offset int64
+	size   int64
+	values int64
+}
+
+type rowGroup struct {
+	rows    int64
+	size    int64
+	columns []columnChunk
+}
+
+// A Writer writes rows to a Parquet file. Close must be called to write the file footer.
+type Writer struct {
+	w            io.Writer
+	columns      []string
+	RowGroupSize int
+	offset       int64
+	rows         [][]*string
+	groups       []rowGroup
+	err          error
+}
+
+// NewWriter returns a Writer of the given columns, writing the file header to w on the first Write.
+func NewWriter(w io.Writer, columns []string) *Writer {
+	return &Writer{w: w, columns: columns, RowGroupSize: DefaultRowGroupSize}
+}
+
+func (w *Writer) write(b []byte) {
+	if w.err != nil {
+		return
+	}
+	n, err := w.w.Write(b)
+	w.offset += int64(n)
+	w.err = err
+}
+
+// Write adds a row, with one value per column, nil for null values.
+func (w *Writer) Write(row []*string) error {
+	if len(row) != len(w.columns) {
+		return errors.New("parquet: row length does not match the columns")
+	}
+	if w.offset == 0 {
+		w.write([]byte(magic))
+	}
+	w.rows = append(w.rows, row)
+	if len(w.rows) >= w.RowGroupSize {
+		w.flush()
+	}
+	return w.err
+}
+
+// flush writes the buffered rows as a row group.
+func (w *Writer) flush() {
+	if len(w.rows) == 0 || w.err != nil {
+		return
+	}
+	group := rowGroup{rows: int64(len(w.rows))}
+	for i := range w.columns {
+		page := columnPage(w.rows, i)
+		var header thrift
+		header.i32(1, pageData)
+		header.i32(2, int32(len(page)))
+		header.i32(3, int32(len(page)))
+		header.begin(5)
+		header.i32(1, int32(len(w.rows)))
+		header.i32(2, encodingPlain)
+		header.i32(3, encodingRLE)
+		header.i32(4, encodingRLE)
+		header.end()
+		header.stop()
+
+		chunk := columnChunk{offset: w.offset, values: int64(len(w.rows))}
+		w.write(header.Bytes())
+		w.write(page)
+		chunk.size = w.offset - chunk.offset
+		group.size += chunk.size
+		group.columns = append(group.columns, chunk)
+	}
+	w.groups = append(w.groups, group)
+	w.rows = w.rows[:0]
+}
+
+// columnPage encodes the definition levels and values of column i.
+func columnPage(rows [][]*string, i int) []byte {
+	// definition levels, bit-packed with width 1 in groups of 8
+	levels := make([]byte, (len(rows)+7)/8)
+	var values bytes.Buffer
+	for j, row := range rows {
+		if v := row[i]; v != nil {
+			levels[j/8] |= 1 << uint(j%8)
+			binary.Write(&values, binary.LittleEndian, uint32(len(*v)))
+			values.WriteString(*v)
+		}
+	}
+	var run bytes.Buffer
+	run.Write(uvarint(uint64(len(levels))<<1 | 1))
+	run.Write(levels)
+
+	var page bytes.Buffer
+	binary.Write(&page, binary.LittleEndian, uint32(run.Len()))
+	page.Write(run.Bytes())
+	page.Write(values.Bytes())
+	return page.Bytes()
+}
+
+// Close writes the remaining rows and the file footer. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.offset == 0 {
+		w.write([]byte(magic))
+	}
+	w.flush()
+
+	var rows int64
+	for _, g := range w.groups {
+		rows += g.rows
+	}
+	var meta thrift
+	meta.i32(1, 1)
+	meta.list(2, typeStruct, len(w.columns)+1)
+	meta.elem()
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(w.columns)))
+	meta.end()
+	for _, name := range w.columns {
+		meta.elem()
+		meta.i32(1, typeByteArray)
+		meta.i32(3, repetitionOptional)
+		meta.binary(4, name)
+		meta.i32(6, convertedUTF8)
+		meta.end()
+	}
+	meta.i64(3, rows)
+	meta.list(4, typeStruct, len(w.groups))
+	for _, g := range w.groups {
+		meta.elem()
+		meta.list(1, typeStruct, len(g.columns))
+		for i, c := range g.columns {
+			meta.elem()
+			meta.i64(2, c.offset)
+			meta.begin(3)
+			meta.i32(1, typeByteArray)
+			meta.list(2, typeI32, 2)
+			meta.varint(encodingPlain)
+			meta.varint(encodingRLE)
+			meta.list(3, typeBinary, 1)
+			meta.string(w.columns[i])
+			meta.i32(4, codecUncompressed)
+			meta.i64(5, c.values)
+			meta.i64(6, c.size)
+			meta.i64(7, c.size)
+			meta.i64(9, c.offset)
+			meta.end()
+			meta.end()
+		}
+		meta.i64(2, g.size)
+		meta.i64(3, g.rows)
+		meta.end()
+	}
+	meta.binary(6, "hebe")
+	meta.stop()
+
+	w.write(meta.Bytes())
+	footer := make([]byte, 4)
+	binary.LittleEndian.PutUint32(footer, uint32(meta.Len()))
+	w.write(footer)
+	w.write([]byte(magic))
+	return w.err
+}
+
+// thrift compact protocol types
+const (
+	typeI32    = 5
+	typeI64    = 6
+	typeBinary = 8
+	typeList   = 9
+	typeStruct = 12
+)
+
+// thrift encodes structs with the thrift compact protocol. Fields of a struct field
+// are written between begin and end, and those of each struct element of a list
+// between elem and end; stop terminates the top level struct.
+type thrift struct {
+	bytes.Buffer
+	last  int16
+	stack []int16
+}
+
+func uvarint(v uint64) []byte {
+	b := make([]byte, binary.MaxVarintLen64)
+	return b[:binary.PutUvarint(b, v)]
+}
+
+func (t *thrift) varint(v int64) {
+	t.Write(uvarint(uint64((v << 1) ^ (v >> 63))))
+}
+
+func (t *thrift) field(id int16, typ byte) {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.WriteByte(typ)
+		t.varint(int64(id))
+	}
+	t.last = id
+}
+
+func (t *thrift) i32(id int16, v int32) {
+	t.field(id, typeI32)
+	t.varint(int64(v))
+}
+
+func (t *thrift) i64(id int16, v int64) {
+	t.field(id, typeI64)
+	t.varint(v)
+}
+
+func (t *thrift) string(s string) {
+	t.Write(uvarint(uint64(len(s))))
+	t.WriteString(s)
+}
+
+func (t *thrift) binary(id int16, s string) {
+	t.field(id, typeBinary)
+	t.string(s)
+}
+
+// list writes the header of a list field of n elements, which follow it.
+func (t *thrift) list(id int16, elem byte, n int) {
+	t.field(id, typeList)
+	if n < 15 {
+		t.WriteByte(byte(n)<<4 | elem)
+	} else {
+		t.WriteByte(0xf0 | elem)
+		t.Write(uvarint(uint64(n)))
+	}
+}
+
+func (t *thrift) begin(id int16) {
+	t.field(id, typeStruct)
+	t.elem()
+}
+
+func (t *thrift) elem() {
+	t.stack = append(t.stack, t.last)
+	t.last = 0
+}
+
+func (t *thrift) end() {
+	t.stop()
+	top := len(t.stack) - 1
+	t.last = t.stack[top]
+	t.stack = t.stack[:top]
+}
+
+func (t *thrift) stop() {
+	t.WriteByte(0)
+}
@@ -0,0 +1,361 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The files written are read back by the reader below, written from the Parquet and thrift
+// compact protocol specifications rather than from the writer: metadata is decoded as
+// generic thrift structs and definition levels as any run length / bit-packed hybrid.
+
+// tstruct is a decoded thrift struct, its values by field id.
+type tstruct map[int16]interface{}
+
+type treader struct {
+	b   []byte
+	pos int
+	err error
+}
+
+func (r *treader) byte() byte {
+	if r.pos >= len(r.b) {
+		r.err = fmt.Errorf("thrift: unexpected end at %d", r.pos)
+		return 0
+	}
+	r.pos++
+	return r.b[r.pos-1]
+}
+
+func (r *treader) uvarint() uint64 {
+	var v uint64
+	for shift := uint(0); r.err == nil; shift += 7 {
+		b := r.byte()
+		v |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	return v
+}
+
+func (r *treader) zigzag() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *treader) bytes(n int) []byte {
+	if r.pos+n > len(r.b) {
+		r.err = fmt.Errorf("thrift: %d bytes past the end at %d", n, r.pos)
+		return nil
+	}
+	r.pos += n
+	return r.b[r.pos-n : r.pos]
+}
+
+func (r *treader) value(typ byte) interface{} {
+	switch typ {
+	case 1:
+		return true
+	case 2:
+		return false
+	case 3:
+		return int64(int8(r.byte()))
+	case 4, 5, 6:
+		return r.zigzag()
+	case 7:
+		return r.bytes(8)
+	case 8:
+		return string(r.bytes(int(r.uvarint())))
+	case 9, 10:
+		header := r.byte()
+		n, elem := int(header>>4), header&0x0f
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		list := make([]interface{}, n)
+		for i := range list {
+			if elem == 1 || elem == 2 {
+				list[i] = r.byte() == 1
+			} else {
+				list[i] = r.value(elem)
+			}
+		}
+		return list
+	case 12:
+		return r.readStruct()
+	}
+	r.err = fmt.Errorf("thrift: unexpected type %d at %d", typ, r.pos)
+	return nil
+}
+
+func (r *treader) readStruct() tstruct {
+	s := tstruct{}
+	var id int16
+	for r.err == nil {
+		header := r.byte()
+		if header == 0 {
+			break
+		}
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(r.zigzag())
+		}
+		s[id] = r.value(header & 0x0f)
+	}
+	return s
+}
+
+func (s tstruct) int(id int16) int64 {
+	v, _ := s[id].(int64)
+	return v
+}
+
+func (s tstruct) list(id int16) []interface{} {
+	v, _ := s[id].([]interface{})
+	return v
+}
+
+// parquetFile is what the reader decodes from a file.
+type parquetFile struct {
+	schema    []tstruct
+	numRows   int64
+	groupRows []int64
+	rows      [][]*string
+}
+
+// hybrid decodes n values of a run length / bit-packed hybrid of the given bit width.
+func hybrid(r *treader, width uint, n int) []int {
+	var values []int
+	byteWidth := int(width+7) / 8
+	for len(values) < n && r.err == nil {
+		header := r.uvarint()
+		if header&1 == 0 {
+			var v int
+			for i, b := range r.bytes(byteWidth) {
+				v |= int(b) << uint(8*i)
+			}
+			for i := uint64(0); i < header>>1; i++ {
+				values = append(values, v)
+			}
+			continue
+		}
+		packed := r.bytes(int(header>>1) * int(width))
+		for bit := uint(0); bit+width <= uint(len(packed))*8; bit += width {
+			var v int
+			for i := uint(0); i < width; i++ {
+				if packed[(bit+i)/8]&(1<<((bit+i)%8)) != 0 {
+					v |= 1 << i
+				}
+			}
+			values = append(values, v)
+		}
+	}
+	if len(values) < n {
+		r.err = fmt.Errorf("%d levels of %d", len(values), n)
+	}
+	return values[:n]
+}
+
+func readParquet(b []byte) (*parquetFile, error) {
+	if len(b) < 12 || string(b[:4]) != magic || string(b[len(b)-4:]) != magic {
+		return nil, fmt.Errorf("no PAR1 magic around %d bytes", len(b))
+	}
+	size := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	footer := &treader{b: b[len(b)-8-size : len(b)-8]}
+	meta := footer.readStruct()
+	if footer.err != nil {
+		return nil, footer.err
+	}
+	if footer.pos != size {
+		return nil, fmt.Errorf("footer of %d bytes, metadata of %d", size, footer.pos)
+	}
+	f := &parquetFile{numRows: meta.int(3)}
+	for _, e := range meta.list(2) {
+		f.schema = append(f.schema, e.(tstruct))
+	}
+	columns := len(f.schema) - 1
+	for _, g := range meta.list(4) {
+		group := g.(tstruct)
+		rows := int(group.int(3))
+		f.groupRows = append(f.groupRows, int64(rows))
+		cells := make([][]*string, rows)
+		for i := range cells {
+			cells[i] = make([]*string, columns)
+		}
+		chunks := group.list(1)
+		if len(chunks) != columns {
+			return nil, fmt.Errorf("%d column chunks for %d columns", len(chunks), columns)
+		}
+		for j, c := range chunks {
+			cm := c.(tstruct)[3].(tstruct)
+			if cm.int(5) != int64(rows) {
+				return nil, fmt.Errorf("column %d has %d values in a group of %d rows", j, cm.int(5), rows)
+			}
+			r := &treader{b: b, pos: int(cm.int(9))}
+			header := r.readStruct()
+			page := &treader{b: r.bytes(int(header.int(3)))}
+			if r.err != nil {
+				return nil, r.err
+			}
+			if int64(r.pos)-cm.int(9) != cm.int(7) {
+				return nil, fmt.Errorf("column %d chunk of %d bytes, page of %d", j, cm.int(7), int64(r.pos)-cm.int(9))
+			}
+			data := header[5].(tstruct)
+			n := int(data.int(1))
+			levelsSize := int(binary.LittleEndian.Uint32(page.bytes(4)))
+			levels := hybrid(&treader{b: page.bytes(levelsSize)}, 1, n)
+			for i, level := range levels {
+				if level == 1 {
+					v := string(page.bytes(int(binary.LittleEndian.Uint32(page.bytes(4)))))
+					cells[i][j] = &v
+				}
+			}
+			if page.err != nil {
+				return nil, page.err
+			}
+			if page.pos != len(page.b) {
+				return nil, fmt.Errorf("column %d page has %d bytes left", j, len(page.b)-page.pos)
+			}
+		}
+		f.rows = append(f.rows, cells...)
+	}
+	return f, nil
+}
+
+func str(s string) *string {
+	return &s
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	wide := make([]string, 20)
+	for i := range wide {
+		wide[i] = fmt.Sprintf("field%d", i)
+	}
+	var many [][]*string
+	for i := 0; i < 1000; i++ {
+		row := make([]*string, len(wide))
+		for j := range row {
+			if (i+j)%3 != 0 {
+				row[j] = str(strings.Repeat(fmt.Sprint(i), j))
+			}
+		}
+		many = append(many, row)
+	}
+	tests := []struct {
+		name         string
+		columns      []string
+		rowGroupSize int
+		rows         [][]*string
+		groups       []int64
+	}{
+		{
+			name:    "no rows",
+			columns: []string{"id"},
+		},
+		{
+			name:    "nulls and empty strings",
+			columns: []string{"id", "name", "comment"},
+			rows: [][]*string{
+				{str("1"), str("kimchy"), nil},
+				{str("2"), nil, str("")},
+				{nil, nil, nil},
+				{str("4"), str("Zoë"), str("日本語")},
+			},
+			groups: []int64{4},
+		},
+		{
+			name:    "nested and repeated values as JSON",
+			columns: []string{"user", "tags"},
+			rows: [][]*string{
+				{str(`{"name":"kimchy","address":{"city":"Amsterdam"}}`), str(`["a","b"]`)},
+				{str(`{}`), str(`[]`)},
+				{nil, str(`[{"k":1},{"k":2}]`)},
+			},
+			groups: []int64{3},
+		},
+		{
+			name:         "multiple row groups",
+			columns:      []string{"id", "value"},
+			rowGroupSize: 3,
+			rows: [][]*string{
+				{str("1"), nil}, {str("2"), str("b")}, {str("3"), str("c")},
+				{str("4"), str("d")}, {nil, nil}, {str("6"), str("f")},
+				{str("7"), str(strings.Repeat("long ", 100))},
+			},
+			groups: []int64{3, 3, 1},
+		},
+		{
+			name:         "many rows and columns",
+			columns:      wide,
+			rowGroupSize: 400,
+			rows:         many,
+			groups:       []int64{400, 400, 200},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var b bytes.Buffer
+			w := NewWriter(&b, test.columns)
+			if test.rowGroupSize > 0 {
+				w.RowGroupSize = test.rowGroupSize
+			}
+			for _, row := range test.rows {
+				if err := w.Write(row); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			f, err := readParquet(b.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.numRows != int64(len(test.rows)) {
+				t.Errorf("num_rows %d, want %d", f.numRows, len(test.rows))
+			}
+			if !reflect.DeepEqual(f.groupRows, test.groups) {
+				t.Errorf("row groups of %v rows, want %v", f.groupRows, test.groups)
+			}
+			root := f.schema[0]
+			if root.int(5) != int64(len(test.columns)) || len(f.schema) != len(test.columns)+1 {
+				t.Fatalf("schema %v for columns %v", f.schema, test.columns)
+			}
+			for i, e := range f.schema[1:] {
+				if e[4] != test.columns[i] || e.int(1) != typeByteArray || e.int(3) != repetitionOptional || e.int(6) != convertedUTF8 {
+					t.Errorf("schema element %v, want optional UTF8 byte array %s", e, test.columns[i])
+				}
+			}
+			if len(f.rows) != len(test.rows) {
+				t.Fatalf("read %d rows, want %d", len(f.rows), len(test.rows))
+			}
+			for i, row := range test.rows {
+				for j, v := range row {
+					if got := f.rows[i][j]; (got == nil) != (v == nil) || got != nil && *got != *v {
+						t.Errorf("row %d column %s is %s, want %s", i, test.columns[j], show(got), show(v))
+					}
+				}
+			}
+		})
+	}
+}
+
+func show(v *string) string {
+	if v == nil {
+		return "null"
+	}
+	return fmt.Sprintf("%q", *v)
+}
+
+func TestWriterRowLength(t *testing.T) {
+	w := NewWriter(&bytes.Buffer{}, []string{"id", "name"})
+	if err := w.Write([]*string{str("1")}); err == nil {
+		t.Error("writing a row of 1 value to 2 columns succeeded")
+	}
+}